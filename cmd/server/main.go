@@ -4,11 +4,18 @@ import (
 	"log"
 	"oauth/config"
 	"oauth/internal/server"
+	"os"
 )
 
 func main() {
 	cfg := config.LoadConfig()
-	err := server.Run(cfg)
+
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		err = server.Keys(cfg, os.Args[2:], os.Stdout)
+	} else {
+		err = server.Run(cfg)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1,14 +1,54 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // Config contains all of the variables required by the auth service
 type Config struct {
-	Port           string
-	DSN            string
-	PrivateKeyPath string
+	Port            string
+	DSN             string
+	PrivateKeyPath  string
+	KeyringPath     string
+	SigningAlg      string
+	Issuer          string
+	PairwiseSalt    string
+	SAMLMetadataDir string
+	// KeyRotationInterval enables scheduled key rotation when non zero
+	KeyRotationInterval time.Duration
+	KeyRotationLeadTime time.Duration
+	KeyRotationGrace    time.Duration
+	// Signer is where the signing key lives: file, pkcs11, kms or postgres
+	Signer           string
+	PKCS11Module     string
+	PKCS11TokenLabel string
+	PKCS11PIN        string
+	PKCS11KeyLabel   string
+	KMSURL           string
+	KMSKeyID         string
+	KMSToken         string
+	// KeyPassphrase decrypts encrypted private keys, KeyPassphraseFile is read
+	// when it is empty and "-" reads the passphrase from stdin
+	KeyPassphrase     string
+	KeyPassphraseFile string
+	// KeyEncryptionKey is the base64 encoded AES-256 key that encrypts keys
+	// stored in Postgres
+	KeyEncryptionKey string
+	// TLSCertPath and TLSKeyPath enable TLS, both files are reloaded on change
+	TLSCertPath string
+	TLSKeyPath  string
+	// ResourceServersDir holds the resource servers tokens can be requested
+	// for, along with the keys their tokens are encrypted to
+	ResourceServersDir string
+	// CIBANotifierURL is the https endpoint CIBA authentication requests are
+	// posted to for delivery to the user's device
+	CIBANotifierURL   string
+	CIBANotifierToken string
+	// AdminAddr is where metrics are served, apart from the API. Empty
+	// disables the admin listener.
+	AdminAddr string
 }
 
 // LoadConfig returns Config struct
@@ -17,11 +57,41 @@ func LoadConfig() *Config {
 	viper.SetDefault("PORT", 3000)
 	viper.SetDefault("DSN", "host=localhost port=5432 user=postgres password=password dbname=auth sslmode=disable")
 	viper.SetDefault("PRIVATE_KEY_PATH", "./certificates/private.pem")
+	viper.SetDefault("ISSUER", "http://localhost:3000")
+	viper.SetDefault("SIGNER", "file")
+	viper.SetDefault("KEY_ROTATION_LEAD_TIME", "48h")
+	viper.SetDefault("KEY_ROTATION_GRACE", "1h")
+	viper.SetDefault("ADMIN_ADDR", "127.0.0.1:3001")
 
 	cfg := &Config{
-		Port:           viper.GetString("PORT"),
-		DSN:            viper.GetString("DSN"),
-		PrivateKeyPath: viper.GetString("PRIVATE_KEY_PATH"),
+		Port:                viper.GetString("PORT"),
+		DSN:                 viper.GetString("DSN"),
+		PrivateKeyPath:      viper.GetString("PRIVATE_KEY_PATH"),
+		KeyringPath:         viper.GetString("KEYRING_PATH"),
+		SigningAlg:          viper.GetString("SIGNING_ALG"),
+		Signer:              viper.GetString("SIGNER"),
+		PKCS11Module:        viper.GetString("PKCS11_MODULE"),
+		PKCS11TokenLabel:    viper.GetString("PKCS11_TOKEN_LABEL"),
+		PKCS11PIN:           viper.GetString("PKCS11_PIN"),
+		PKCS11KeyLabel:      viper.GetString("PKCS11_KEY_LABEL"),
+		KMSURL:              viper.GetString("KMS_URL"),
+		KMSKeyID:            viper.GetString("KMS_KEY_ID"),
+		KMSToken:            viper.GetString("KMS_TOKEN"),
+		KeyPassphrase:       viper.GetString("KEY_PASSPHRASE"),
+		KeyPassphraseFile:   viper.GetString("KEY_PASSPHRASE_FILE"),
+		KeyEncryptionKey:    viper.GetString("KEY_ENCRYPTION_KEY"),
+		TLSCertPath:         viper.GetString("TLS_CERT_PATH"),
+		TLSKeyPath:          viper.GetString("TLS_KEY_PATH"),
+		ResourceServersDir:  viper.GetString("RESOURCE_SERVERS_DIR"),
+		CIBANotifierURL:     viper.GetString("CIBA_NOTIFIER_URL"),
+		CIBANotifierToken:   viper.GetString("CIBA_NOTIFIER_TOKEN"),
+		AdminAddr:           viper.GetString("ADMIN_ADDR"),
+		Issuer:              viper.GetString("ISSUER"),
+		PairwiseSalt:        viper.GetString("PAIRWISE_SALT"),
+		SAMLMetadataDir:     viper.GetString("SAML_METADATA_DIR"),
+		KeyRotationInterval: viper.GetDuration("KEY_ROTATION_INTERVAL"),
+		KeyRotationLeadTime: viper.GetDuration("KEY_ROTATION_LEAD_TIME"),
+		KeyRotationGrace:    viper.GetDuration("KEY_ROTATION_GRACE"),
 	}
 
 	return cfg
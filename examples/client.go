@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -54,7 +53,7 @@ func main() {
 	}
 
 	// validate jwt using public key
-	validator := jwt.NewValidator(k.(*rsa.PublicKey))
+	validator := jwt.NewValidator(k)
 	_, err = validator.Validate(token)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,85 @@
+package ciba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"oauth/internal/models"
+	"time"
+)
+
+// Notifier delivers an authentication request to the user's authentication device.
+// The device reports the user's decision back using the request's callback token.
+type Notifier interface {
+	Notify(ctx context.Context, req *models.BackchannelAuthRequest) error
+}
+
+// HTTPNotifier posts authentication requests to the service that reaches the
+// user's authentication device
+type HTTPNotifier struct {
+	endpoint string
+	token    string
+	c        *http.Client
+}
+
+type notification struct {
+	AuthReqID      string    `json:"auth_req_id"`
+	Scope          string    `json:"scope"`
+	LoginHint      string    `json:"login_hint"`
+	BindingMessage string    `json:"binding_message,omitempty"`
+	ACRValues      string    `json:"acr_values,omitempty"`
+	CallbackToken  string    `json:"callback_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// NewHTTPNotifier returns a Notifier for endpoint, which must use https since
+// the callback token lets the holder decide the request. token authenticates
+// the server to the endpoint.
+func NewHTTPNotifier(endpoint string, token string) (*HTTPNotifier, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("notifier endpoint must be an https URL: %q", endpoint)
+	}
+
+	return &HTTPNotifier{endpoint, token, &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Notify posts req to the endpoint
+func (hn *HTTPNotifier) Notify(ctx context.Context, req *models.BackchannelAuthRequest) error {
+	body, err := json.Marshal(notification{
+		AuthReqID:      req.ID,
+		Scope:          req.Scope,
+		LoginHint:      req.LoginHint,
+		BindingMessage: req.BindingMessage,
+		ACRValues:      req.ACRValues,
+		CallbackToken:  req.CallbackToken,
+		ExpiresAt:      req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, hn.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if hn.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+hn.token)
+	}
+
+	resp, err := hn.c.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("unable to notify device: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unable to notify device: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
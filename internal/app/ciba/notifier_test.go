@@ -0,0 +1,55 @@
+package ciba
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"oauth/internal/models"
+	"testing"
+	"time"
+)
+
+func TestHTTPNotifier(t *testing.T) {
+	var got notification
+	var auth string
+	device := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		err := json.NewDecoder(r.Body).Decode(&got)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer device.Close()
+
+	notifier, err := NewHTTPNotifier(device.URL, "device-token")
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %s", err)
+	}
+	notifier.c = device.Client()
+
+	req := &models.BackchannelAuthRequest{
+		ID:            "req-1",
+		Scope:         "openid",
+		LoginHint:     "user@example.com",
+		CallbackToken: "callback",
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	err = notifier.Notify(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to notify: %s", err)
+	}
+	if auth != "Bearer device-token" {
+		t.Fatalf("Expected bearer token, got %q", auth)
+	}
+	if got.AuthReqID != req.ID || got.CallbackToken != req.CallbackToken || got.LoginHint != req.LoginHint {
+		t.Fatalf("Expected request to be delivered, got %+v", got)
+	}
+
+	for _, endpoint := range []string{"", "http://device.example.com/notify", "https://"} {
+		_, err := NewHTTPNotifier(endpoint, "")
+		if err == nil {
+			t.Fatalf("Expected endpoint %q to be rejected, got nil", endpoint)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package ciba
+
+import (
+	"context"
+	"fmt"
+	"oauth/internal/models"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type Repository interface {
+	Create(ctx context.Context, req *models.BackchannelAuthRequest) error
+	GetByID(ctx context.Context, id string) (*models.BackchannelAuthRequest, error)
+	// UpdateResult stores the decision on req if it is still pending and
+	// reports whether it was
+	UpdateResult(ctx context.Context, req *models.BackchannelAuthRequest) (bool, error)
+	UpdateLastPolledAt(ctx context.Context, id string, at time.Time) error
+	// DeleteApproved deletes the request if it is approved and returns it, or
+	// nil when it is not approved or already deleted
+	DeleteApproved(ctx context.Context, id string) (*models.BackchannelAuthRequest, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type cibaRepository struct {
+	pool   *pgxpool.Pool
+	ticker time.Ticker
+	done   chan bool
+	once   sync.Once
+}
+
+func NewRepository(pool *pgxpool.Pool) (*cibaRepository, error) {
+	repo := &cibaRepository{pool: pool, ticker: *time.NewTicker(5 * time.Minute), done: make(chan bool)}
+	err := repo.initTable()
+	if err != nil {
+		return nil, err
+	}
+	go repo.gc()
+
+	return repo, nil
+}
+
+// Close stops the expired row cleanup. It does not block and may be called
+// more than once.
+func (cr *cibaRepository) Close() {
+	cr.once.Do(func() {
+		close(cr.done)
+	})
+}
+
+func (cr *cibaRepository) initTable() error {
+	_, err := cr.pool.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS backchannel_auth_request (
+	id							TEXT		PRIMARY KEY,
+	client_id					TEXT		NOT NULL REFERENCES client (id) ON DELETE CASCADE,
+	scope						TEXT		NOT NULL,
+	login_hint					TEXT		NOT NULL,
+	binding_message				TEXT		NOT NULL DEFAULT '',
+	client_notification_token	TEXT		NOT NULL DEFAULT '',
+	callback_token				TEXT		NOT NULL,
+	status						TEXT		NOT NULL,
+	interval					INTEGER		NOT NULL,
+	expires_at					TIMESTAMPTZ NOT NULL,
+	last_polled_at				TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+	created_at					TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE backchannel_auth_request ADD COLUMN IF NOT EXISTS acr_values TEXT NOT NULL DEFAULT '';
+	ALTER TABLE backchannel_auth_request ADD COLUMN IF NOT EXISTS acr TEXT NOT NULL DEFAULT '';
+	ALTER TABLE backchannel_auth_request ADD COLUMN IF NOT EXISTS amr TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE backchannel_auth_request ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
+	CREATE INDEX IF NOT EXISTS idx_backchannel_auth_request_expires_at ON backchannel_auth_request (expires_at);
+	`)
+	return err
+}
+
+func (cr *cibaRepository) gc() {
+	for {
+		select {
+		case <-cr.done:
+			return
+		case <-cr.ticker.C:
+			_, err := cr.pool.Exec(context.Background(), `
+			DELETE FROM backchannel_auth_request WHERE expires_at < $1;
+			`, time.Now())
+			// a failed cleanup is retried on the next tick
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func (cr *cibaRepository) Create(ctx context.Context, req *models.BackchannelAuthRequest) error {
+	_, err := cr.pool.Exec(ctx, `
+	INSERT INTO backchannel_auth_request
+	(id, client_id, scope, login_hint, binding_message, acr_values, client_notification_token, callback_token,
+	status, interval, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, req.ID, req.ClientID, req.Scope, req.LoginHint, req.BindingMessage, req.ACRValues, req.ClientNotificationToken,
+		req.CallbackToken, req.Status, req.Interval, req.ExpiresAt)
+	return err
+}
+
+const requestColumns = `id, client_id, scope, login_hint, binding_message, acr_values, client_notification_token,
+	callback_token, status, acr, amr, auth_time, interval, expires_at, last_polled_at`
+
+func scanRequest(row pgx.Row) (*models.BackchannelAuthRequest, error) {
+	var req models.BackchannelAuthRequest
+	err := row.Scan(
+		&req.ID,
+		&req.ClientID,
+		&req.Scope,
+		&req.LoginHint,
+		&req.BindingMessage,
+		&req.ACRValues,
+		&req.ClientNotificationToken,
+		&req.CallbackToken,
+		&req.Status,
+		&req.ACR,
+		&req.AMR,
+		&req.AuthTime,
+		&req.Interval,
+		&req.ExpiresAt,
+		&req.LastPolledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (cr *cibaRepository) GetByID(ctx context.Context, id string) (*models.BackchannelAuthRequest, error) {
+	return scanRequest(cr.pool.QueryRow(ctx, `
+	SELECT `+requestColumns+`
+	FROM public.backchannel_auth_request WHERE id = $1
+	`, id))
+}
+
+func (cr *cibaRepository) UpdateResult(ctx context.Context, req *models.BackchannelAuthRequest) (bool, error) {
+	tag, err := cr.pool.Exec(ctx, `
+	UPDATE backchannel_auth_request SET status = $2, acr = $3, amr = $4, auth_time = $5
+	WHERE id = $1 AND status = 'pending'
+	`, req.ID, req.Status, req.ACR, req.AMR, req.AuthTime)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (cr *cibaRepository) UpdateLastPolledAt(ctx context.Context, id string, at time.Time) error {
+	_, err := cr.pool.Exec(ctx, `
+	UPDATE backchannel_auth_request SET last_polled_at = $2 WHERE id = $1
+	`, id, at)
+	return err
+}
+
+func (cr *cibaRepository) DeleteApproved(ctx context.Context, id string) (*models.BackchannelAuthRequest, error) {
+	req, err := scanRequest(cr.pool.QueryRow(ctx, `
+	DELETE FROM backchannel_auth_request WHERE id = $1 AND status = 'approved'
+	RETURNING `+requestColumns, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return req, err
+}
+
+func (cr *cibaRepository) Delete(ctx context.Context, id string) error {
+	_, err := cr.pool.Exec(ctx, `
+	DELETE FROM backchannel_auth_request WHERE id = $1
+	`, id)
+	return err
+}
@@ -0,0 +1,218 @@
+package ciba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"oauth/internal/errors"
+	"oauth/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a backchannel authentication request
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+)
+
+const (
+	defaultExpiry   = 2 * time.Minute
+	maxExpiry       = 10 * time.Minute
+	defaultInterval = 5
+)
+
+type Service interface {
+	Enabled() bool
+	Create(ctx context.Context, client *models.Client, req *models.BackchannelAuthRequest, expiry time.Duration) (*models.BackchannelAuthRequest, error)
+	Resolve(ctx context.Context, id string, callbackToken string, approved bool, acr string, amr []string) (*models.BackchannelAuthRequest, error)
+	Redeem(ctx context.Context, client *models.Client, id string) (*models.BackchannelAuthRequest, error)
+	Ping(ctx context.Context, client *models.Client, req *models.BackchannelAuthRequest) error
+}
+
+type cibaService struct {
+	r Repository
+	n Notifier
+	c *http.Client
+}
+
+// NewService returns a Service that delivers requests through notifier. CIBA
+// is disabled when notifier is nil.
+func NewService(repo Repository, notifier Notifier) *cibaService {
+	return &cibaService{repo, notifier, &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Enabled reports whether authentication requests can be delivered
+func (cs *cibaService) Enabled() bool {
+	return cs.n != nil
+}
+
+// Create validates and stores a new authentication request, then hands it to the Notifier
+func (cs *cibaService) Create(ctx context.Context, client *models.Client, req *models.BackchannelAuthRequest, expiry time.Duration) (*models.BackchannelAuthRequest, error) {
+	if !cs.Enabled() || client.BackchannelTokenDeliveryMode == "" {
+		return nil, errors.ErrUnauthorizedClient
+	}
+	if !hasScope(req.Scope, "openid") || req.LoginHint == "" {
+		return nil, errors.ErrInvalidRequest
+	}
+	if client.BackchannelTokenDeliveryMode == models.DeliveryModePing && req.ClientNotificationToken == "" {
+		return nil, errors.ErrInvalidRequest
+	}
+	if expiry <= 0 {
+		expiry = defaultExpiry
+	} else if expiry > maxExpiry {
+		expiry = maxExpiry
+	}
+
+	r := &models.BackchannelAuthRequest{
+		ID:                      uuid.New().String(),
+		ClientID:                client.ID,
+		Scope:                   req.Scope,
+		LoginHint:               req.LoginHint,
+		BindingMessage:          req.BindingMessage,
+		ACRValues:               req.ACRValues,
+		ClientNotificationToken: req.ClientNotificationToken,
+		CallbackToken:           uuid.New().String(),
+		Status:                  StatusPending,
+		Interval:                defaultInterval,
+		ExpiresAt:               time.Now().Add(expiry),
+	}
+
+	err := cs.r.Create(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cs.n.Notify(ctx, r)
+	if err != nil {
+		cs.r.Delete(ctx, r.ID)
+		return nil, fmt.Errorf("unable to notify authentication device: %s", err)
+	}
+
+	return r, nil
+}
+
+// Resolve records the user's decision reported by the authentication device,
+// along with the acr and amr it authenticated the user with. An approval at an
+// acr the client did not ask for is rejected and the request stays pending, so
+// the device can step up authentication and report again.
+func (cs *cibaService) Resolve(ctx context.Context, id string, callbackToken string, approved bool, acr string, amr []string) (*models.BackchannelAuthRequest, error) {
+	r, err := cs.r.GetByID(ctx, id)
+	if err != nil || r.CallbackToken != callbackToken {
+		return nil, errors.ErrInvalidGrant
+	}
+	if r.Status != StatusPending {
+		return nil, errors.ErrInvalidGrant
+	}
+	if time.Now().After(r.ExpiresAt) {
+		return nil, errors.ErrExpiredToken
+	}
+
+	r.Status = StatusDenied
+	if approved {
+		if r.ACRValues != "" && !hasScope(r.ACRValues, acr) {
+			return nil, errors.ErrInsufficientAuth
+		}
+		r.Status = StatusApproved
+		r.ACR = acr
+		r.AMR = amr
+		r.AuthTime = time.Now()
+	}
+	// another report may have resolved the request since it was read
+	updated, err := cs.r.UpdateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	return r, nil
+}
+
+// Redeem exchanges an approved request for the client that made it. Each
+// request can only be redeemed once.
+func (cs *cibaService) Redeem(ctx context.Context, client *models.Client, id string) (*models.BackchannelAuthRequest, error) {
+	r, err := cs.r.GetByID(ctx, id)
+	if err != nil || r.ClientID != client.ID {
+		return nil, errors.ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if now.After(r.ExpiresAt) {
+		cs.r.Delete(ctx, r.ID)
+		return nil, errors.ErrExpiredToken
+	}
+
+	switch r.Status {
+	case StatusApproved:
+		// only one of several concurrent redemptions deletes the request
+		approved, err := cs.r.DeleteApproved(ctx, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if approved == nil {
+			return nil, errors.ErrInvalidGrant
+		}
+		return approved, nil
+	case StatusDenied:
+		cs.r.Delete(ctx, r.ID)
+		return nil, errors.ErrAccessDenied
+	}
+
+	if client.BackchannelTokenDeliveryMode == models.DeliveryModePoll && now.Sub(r.LastPolledAt) < time.Duration(r.Interval)*time.Second {
+		return nil, errors.ErrSlowDown
+	}
+	err = cs.r.UpdateLastPolledAt(ctx, r.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, errors.ErrAuthorizationPending
+}
+
+type pingRequest struct {
+	AuthReqID string `json:"auth_req_id"`
+}
+
+// Ping tells a ping mode client that the result of req can be collected from the token endpoint
+func (cs *cibaService) Ping(ctx context.Context, client *models.Client, req *models.BackchannelAuthRequest) error {
+	body, err := json.Marshal(pingRequest{AuthReqID: req.ID})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelClientNotificationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+req.ClientNotificationToken)
+
+	resp, err := cs.c.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("unable to ping client: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unable to ping client: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// hasScope reports whether want is one of the space separated values in scope
+func hasScope(scope string, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,427 @@
+package ciba
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"oauth/internal/errors"
+	"oauth/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	mu       sync.Mutex
+	requests map[string]models.BackchannelAuthRequest
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{requests: make(map[string]models.BackchannelAuthRequest)}
+}
+
+func (mr *memoryRepository) Create(ctx context.Context, req *models.BackchannelAuthRequest) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.requests[req.ID] = *req
+	return nil
+}
+
+func (mr *memoryRepository) GetByID(ctx context.Context, id string) (*models.BackchannelAuthRequest, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	req, ok := mr.requests[id]
+	if !ok {
+		return nil, fmt.Errorf("not found")
+	}
+	return &req, nil
+}
+
+func (mr *memoryRepository) UpdateResult(ctx context.Context, req *models.BackchannelAuthRequest) (bool, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	stored, ok := mr.requests[req.ID]
+	if !ok || stored.Status != StatusPending {
+		return false, nil
+	}
+	stored.Status = req.Status
+	stored.ACR = req.ACR
+	stored.AMR = req.AMR
+	stored.AuthTime = req.AuthTime
+	mr.requests[req.ID] = stored
+	return true, nil
+}
+
+func (mr *memoryRepository) UpdateLastPolledAt(ctx context.Context, id string, at time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	req := mr.requests[id]
+	req.LastPolledAt = at
+	mr.requests[id] = req
+	return nil
+}
+
+func (mr *memoryRepository) DeleteApproved(ctx context.Context, id string) (*models.BackchannelAuthRequest, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	req, ok := mr.requests[id]
+	if !ok || req.Status != StatusApproved {
+		return nil, nil
+	}
+	delete(mr.requests, id)
+	return &req, nil
+}
+
+func (mr *memoryRepository) Delete(ctx context.Context, id string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.requests, id)
+	return nil
+}
+
+// recordingNotifier keeps delivered requests in memory in place of a device
+type recordingNotifier struct {
+	mu       sync.Mutex
+	requests []*models.BackchannelAuthRequest
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{}
+}
+
+func (rn *recordingNotifier) Notify(ctx context.Context, req *models.BackchannelAuthRequest) error {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	r := *req
+	rn.requests = append(rn.requests, &r)
+	return nil
+}
+
+func (rn *recordingNotifier) Requests() []*models.BackchannelAuthRequest {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	return append([]*models.BackchannelAuthRequest(nil), rn.requests...)
+}
+
+func TestPollFlow(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	notifier := newRecordingNotifier()
+	service := NewService(repo, notifier)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{
+		Scope:          "openid",
+		LoginHint:      "user@example.com",
+		BindingMessage: "1234",
+	}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+
+	delivered := notifier.Requests()
+	if len(delivered) != 1 || delivered[0].ID != req.ID {
+		t.Fatalf("Expected request to be delivered to the notifier, got %v", delivered)
+	}
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != errors.ErrAuthorizationPending {
+		t.Fatalf("Expected authorization pending, got %v", err)
+	}
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != errors.ErrSlowDown {
+		t.Fatalf("Expected slow down, got %v", err)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, "wrong", true, "", nil)
+	if err != errors.ErrInvalidGrant {
+		t.Fatalf("Expected invalid grant for wrong callback token, got %v", err)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, delivered[0].CallbackToken, true, "", []string{"pwd"})
+	if err != nil {
+		t.Fatalf("Failed to resolve request: %s", err)
+	}
+
+	redeemed, err := service.Redeem(ctx, client, req.ID)
+	if err != nil {
+		t.Fatalf("Failed to redeem request: %s", err)
+	}
+	if redeemed.LoginHint != "user@example.com" {
+		t.Fatalf("Unexpected login hint: %s", redeemed.LoginHint)
+	}
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != errors.ErrInvalidGrant {
+		t.Fatalf("Expected request to be single use, got %v", err)
+	}
+}
+
+func TestDenied(t *testing.T) {
+	ctx := context.Background()
+	notifier := newRecordingNotifier()
+	service := NewService(newMemoryRepository(), notifier)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, notifier.Requests()[0].CallbackToken, false, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to resolve request: %s", err)
+	}
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != errors.ErrAccessDenied {
+		t.Fatalf("Expected access denied, got %v", err)
+	}
+}
+
+func TestStepUp(t *testing.T) {
+	ctx := context.Background()
+	notifier := newRecordingNotifier()
+	service := NewService(newMemoryRepository(), notifier)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{
+		Scope:     "openid",
+		LoginHint: "user",
+		ACRValues: "urn:example:mfa",
+	}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+	delivered := notifier.Requests()[0]
+	if delivered.ACRValues != "urn:example:mfa" {
+		t.Fatalf("Expected acr_values to reach the device, got %q", delivered.ACRValues)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, delivered.CallbackToken, true, "urn:example:pwd", []string{"pwd"})
+	if err != errors.ErrInsufficientAuth {
+		t.Fatalf("Expected insufficient authentication, got %v", err)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, delivered.CallbackToken, true, "urn:example:mfa", []string{"pwd", "otp"})
+	if err != nil {
+		t.Fatalf("Failed to resolve request after step up: %s", err)
+	}
+
+	redeemed, err := service.Redeem(ctx, client, req.ID)
+	if err != nil {
+		t.Fatalf("Failed to redeem request: %s", err)
+	}
+	if redeemed.ACR != "urn:example:mfa" || len(redeemed.AMR) != 2 || redeemed.AuthTime.IsZero() {
+		t.Fatalf("Unexpected authentication result: acr=%s amr=%v auth_time=%v", redeemed.ACR, redeemed.AMR, redeemed.AuthTime)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepository()
+	service := NewService(repo, newRecordingNotifier())
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+	stored := repo.requests[req.ID]
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+	repo.requests[req.ID] = stored
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != errors.ErrExpiredToken {
+		t.Fatalf("Expected expired token, got %v", err)
+	}
+}
+
+func TestRedeemByOtherClient(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(newMemoryRepository(), newRecordingNotifier())
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+	other := &models.Client{ID: "other", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+
+	_, err = service.Redeem(ctx, other, req.ID)
+	if err != errors.ErrInvalidGrant {
+		t.Fatalf("Expected invalid grant, got %v", err)
+	}
+}
+
+func TestConcurrentResolveAndRedeem(t *testing.T) {
+	ctx := context.Background()
+	notifier := newRecordingNotifier()
+	service := NewService(newMemoryRepository(), notifier)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+	callbackToken := notifier.Requests()[0].CallbackToken
+
+	// the first decision wins, later reports cannot overwrite it
+	const n = 10
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(approved bool) {
+			_, err := service.Resolve(ctx, req.ID, callbackToken, approved, "", nil)
+			results <- err
+		}(i%2 == 0)
+	}
+	resolved := 0
+	for i := 0; i < n; i++ {
+		if err := <-results; err == nil {
+			resolved++
+		} else if err != errors.ErrInvalidGrant {
+			t.Fatalf("Expected invalid grant for a later decision, got %v", err)
+		}
+	}
+	if resolved != 1 {
+		t.Fatalf("Expected exactly one decision to be recorded, got %d", resolved)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, callbackToken, false, "", nil)
+	if err != errors.ErrInvalidGrant {
+		t.Fatalf("Expected invalid grant, got %v", err)
+	}
+
+	_, err = service.Resolve(ctx, req.ID, callbackToken, true, "", nil)
+	if err != errors.ErrInvalidGrant {
+		t.Fatalf("Expected invalid grant, got %v", err)
+	}
+}
+
+func TestConcurrentRedeem(t *testing.T) {
+	ctx := context.Background()
+	notifier := newRecordingNotifier()
+	service := NewService(newMemoryRepository(), notifier)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePing}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user", ClientNotificationToken: "notify-me"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+	_, err = service.Resolve(ctx, req.ID, notifier.Requests()[0].CallbackToken, true, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to resolve request: %s", err)
+	}
+
+	const n = 10
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := service.Redeem(ctx, client, req.ID)
+			results <- err
+		}()
+	}
+	redeemed := 0
+	for i := 0; i < n; i++ {
+		if err := <-results; err == nil {
+			redeemed++
+		} else if err != errors.ErrInvalidGrant {
+			t.Fatalf("Expected invalid grant for a repeated redemption, got %v", err)
+		}
+	}
+	if redeemed != 1 {
+		t.Fatalf("Expected the request to be redeemed exactly once, got %d", redeemed)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	service := NewService(newMemoryRepository(), nil)
+	client := &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}
+
+	if service.Enabled() {
+		t.Fatal("Expected CIBA to be disabled without a notifier")
+	}
+	_, err := service.Create(context.Background(), client, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, 0)
+	if err != errors.ErrUnauthorizedClient {
+		t.Fatalf("Expected unauthorized client, got %v", err)
+	}
+}
+
+func TestCreateInvalidRequest(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(newMemoryRepository(), newRecordingNotifier())
+
+	tests := []struct {
+		name   string
+		client *models.Client
+		req    *models.BackchannelAuthRequest
+		err    error
+	}{
+		{"not a ciba client", &models.Client{ID: "client"}, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, errors.ErrUnauthorizedClient},
+		{"missing openid scope", &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}, &models.BackchannelAuthRequest{Scope: "profile", LoginHint: "user"}, errors.ErrInvalidRequest},
+		{"missing login hint", &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePoll}, &models.BackchannelAuthRequest{Scope: "openid"}, errors.ErrInvalidRequest},
+		{"ping without notification token", &models.Client{ID: "client", BackchannelTokenDeliveryMode: models.DeliveryModePing}, &models.BackchannelAuthRequest{Scope: "openid", LoginHint: "user"}, errors.ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		_, err := service.Create(ctx, tt.client, tt.req, 0)
+		if err != tt.err {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer notify-me" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body pingRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body.AuthReqID
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	notifier := newRecordingNotifier()
+	service := NewService(newMemoryRepository(), notifier)
+	client := &models.Client{
+		ID:                                    "client",
+		BackchannelTokenDeliveryMode:          models.DeliveryModePing,
+		BackchannelClientNotificationEndpoint: receiver.URL,
+	}
+
+	req, err := service.Create(ctx, client, &models.BackchannelAuthRequest{
+		Scope:                   "openid",
+		LoginHint:               "user",
+		ClientNotificationToken: "notify-me",
+	}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err)
+	}
+
+	resolved, err := service.Resolve(ctx, req.ID, notifier.Requests()[0].CallbackToken, true, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to resolve request: %s", err)
+	}
+
+	err = service.Ping(ctx, client, resolved)
+	if err != nil {
+		t.Fatalf("Failed to ping client: %s", err)
+	}
+
+	if id := <-received; id != req.ID {
+		t.Fatalf("Expected ping for %s, got %s", req.ID, id)
+	}
+
+	_, err = service.Redeem(ctx, client, req.ID)
+	if err != nil {
+		t.Fatalf("Failed to redeem request: %s", err)
+	}
+}
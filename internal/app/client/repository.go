@@ -33,25 +33,45 @@ func (cr *clientRepository) initTable() error {
 	id     TEXT  PRIMARY KEY,
 	secret TEXT  NOT NULL
 	);
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS backchannel_token_delivery_mode TEXT NOT NULL DEFAULT '';
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS backchannel_client_notification_endpoint TEXT NOT NULL DEFAULT '';
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS jwks TEXT NOT NULL DEFAULT '';
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS request_uris TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS require_signed_request_object BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS subject_type TEXT NOT NULL DEFAULT 'public';
+	ALTER TABLE client ADD COLUMN IF NOT EXISTS sector_identifier_uri TEXT NOT NULL DEFAULT '';
 	`)
 	return err
 }
 
 func (cr *clientRepository) Create(ctx context.Context, client *models.Client) error {
 	_, err := cr.pool.Exec(ctx, `
-	INSERT INTO client (id, secret)
-	VALUES ($1, $2);
-	`, client.ID, client.Secret)
+	INSERT INTO client (id, secret, backchannel_token_delivery_mode, backchannel_client_notification_endpoint,
+	jwks, request_uris, require_signed_request_object, subject_type, sector_identifier_uri)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`, client.ID, client.Secret, client.BackchannelTokenDeliveryMode, client.BackchannelClientNotificationEndpoint,
+		client.JWKS, client.RequestURIs, client.RequireSignedRequestObject, client.SubjectType, client.SectorIdentifierURI)
 	return err
 }
 
 func (cr *clientRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
 	var client models.Client
 
-	rows := cr.pool.QueryRow(ctx, "SELECT * FROM public.client WHERE id = $1", id)
+	rows := cr.pool.QueryRow(ctx, `
+	SELECT id, secret, backchannel_token_delivery_mode, backchannel_client_notification_endpoint,
+	jwks, request_uris, require_signed_request_object, subject_type, sector_identifier_uri
+	FROM public.client WHERE id = $1
+	`, id)
 	err := rows.Scan(
 		&client.ID,
 		&client.Secret,
+		&client.BackchannelTokenDeliveryMode,
+		&client.BackchannelClientNotificationEndpoint,
+		&client.JWKS,
+		&client.RequestURIs,
+		&client.RequireSignedRequestObject,
+		&client.SubjectType,
+		&client.SectorIdentifierURI,
 	)
 	if err != nil {
 		return nil, err
@@ -2,38 +2,64 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"oauth/internal/errors"
 	"oauth/internal/models"
+	"oauth/pkg/jwt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// maxSectorIdentifierSize limits how much is read from a sector_identifier_uri
+const maxSectorIdentifierSize = 64 << 10
+
 type Service interface {
-	Create(ctx context.Context) (*models.Client, error)
+	Create(ctx context.Context, metadata *models.Client) (*models.Client, error)
 	GetByID(ctx context.Context, id string) (*models.Client, error)
 }
 
 type clientService struct {
 	r Repository
+	c *http.Client
 }
 
 func NewService(repo Repository) *clientService {
-	return &clientService{repo}
+	return &clientService{repo, &http.Client{Timeout: 10 * time.Second}}
 }
 
-func (cs *clientService) Create(ctx context.Context) (*models.Client, error) {
-	clientID := uuid.New().String()
-	clientSecret := uuid.New().String()
-	client := &models.Client{
-		ID:     clientID,
-		Secret: clientSecret,
+func (cs *clientService) Create(ctx context.Context, metadata *models.Client) (*models.Client, error) {
+	client := *metadata
+	if client.SubjectType == "" {
+		client.SubjectType = models.SubjectTypePublic
 	}
 
-	err := cs.r.Create(ctx, client)
+	err := validateMetadata(&client)
 	if err != nil {
 		return nil, err
 	}
 
-	return client, nil
+	if client.SubjectType == models.SubjectTypePairwise {
+		err = cs.validateSectorIdentifier(ctx, &client)
+		if err != nil {
+			fmt.Println(err)
+			return nil, errors.ErrInvalidClientMetadata
+		}
+	}
+
+	client.ID = uuid.New().String()
+	client.Secret = uuid.New().String()
+
+	err = cs.r.Create(ctx, &client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client, nil
 }
 
 func (cs *clientService) GetByID(ctx context.Context, id string) (*models.Client, error) {
@@ -43,3 +69,102 @@ func (cs *clientService) GetByID(ctx context.Context, id string) (*models.Client
 	}
 	return client, nil
 }
+
+func validateMetadata(c *models.Client) error {
+	switch c.BackchannelTokenDeliveryMode {
+	case "", models.DeliveryModePoll:
+		if c.BackchannelClientNotificationEndpoint != "" {
+			return errors.ErrInvalidClientMetadata
+		}
+	case models.DeliveryModePing:
+		// the endpoint receives the client_notification_token as a bearer token
+		u, err := url.Parse(c.BackchannelClientNotificationEndpoint)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.ErrInvalidClientMetadata
+		}
+	default:
+		return errors.ErrInvalidClientMetadata
+	}
+
+	if c.JWKS != "" {
+		_, err := jwt.ParseJWKS([]byte(c.JWKS))
+		if err != nil {
+			return errors.ErrInvalidClientMetadata
+		}
+	} else if c.RequireSignedRequestObject || len(c.RequestURIs) > 0 {
+		return errors.ErrInvalidClientMetadata
+	}
+
+	for _, uri := range c.RequestURIs {
+		if !isHTTPURL(uri) {
+			return errors.ErrInvalidClientMetadata
+		}
+	}
+
+	switch c.SubjectType {
+	case models.SubjectTypePublic:
+		if c.SectorIdentifierURI != "" {
+			return errors.ErrInvalidClientMetadata
+		}
+	case models.SubjectTypePairwise:
+		u, err := url.Parse(c.SectorIdentifierURI)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.ErrInvalidClientMetadata
+		}
+	default:
+		return errors.ErrInvalidClientMetadata
+	}
+
+	return nil
+}
+
+// validateSectorIdentifier checks that the sector_identifier_uri serves a JSON
+// array of URIs that includes every URI the client registered
+func (cs *clientService) validateSectorIdentifier(ctx context.Context, c *models.Client) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.SectorIdentifierURI, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := cs.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to fetch sector_identifier_uri: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to fetch sector_identifier_uri: unexpected status %d", resp.StatusCode)
+	}
+
+	var uris []string
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxSectorIdentifierSize)).Decode(&uris)
+	if err != nil {
+		return fmt.Errorf("sector_identifier_uri is not a JSON array of URIs: %s", err)
+	}
+
+	listed := make(map[string]bool, len(uris))
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() {
+			return fmt.Errorf("sector_identifier_uri lists an invalid URI: %s", uri)
+		}
+		listed[uri] = true
+	}
+
+	registered := append([]string{}, c.RequestURIs...)
+	if c.BackchannelClientNotificationEndpoint != "" {
+		registered = append(registered, c.BackchannelClientNotificationEndpoint)
+	}
+	for _, uri := range registered {
+		if !listed[uri] {
+			return fmt.Errorf("sector_identifier_uri does not list %s", uri)
+		}
+	}
+
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs() && (u.Scheme == "https" || u.Scheme == "http")
+}
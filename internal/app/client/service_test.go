@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"oauth/internal/errors"
+	"oauth/internal/models"
+	"testing"
+)
+
+type memoryRepository struct {
+	clients map[string]*models.Client
+}
+
+func (mr *memoryRepository) Create(ctx context.Context, client *models.Client) error {
+	mr.clients[client.ID] = client
+	return nil
+}
+
+func (mr *memoryRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
+	return mr.clients[id], nil
+}
+
+func TestCreateDefaultsToPublicSubject(t *testing.T) {
+	service := NewService(&memoryRepository{map[string]*models.Client{}})
+
+	client, err := service.Create(context.Background(), &models.Client{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+	if client.SubjectType != models.SubjectTypePublic {
+		t.Fatalf("Expected public subject type, got %s", client.SubjectType)
+	}
+}
+
+func TestCreatePingRequiresHTTPS(t *testing.T) {
+	service := NewService(&memoryRepository{map[string]*models.Client{}})
+
+	tests := []struct {
+		endpoint string
+		err      error
+	}{
+		{"https://client.example.com/notify", nil},
+		{"http://client.example.com/notify", errors.ErrInvalidClientMetadata},
+		{"", errors.ErrInvalidClientMetadata},
+	}
+
+	for _, tt := range tests {
+		_, err := service.Create(context.Background(), &models.Client{
+			BackchannelTokenDeliveryMode:          models.DeliveryModePing,
+			BackchannelClientNotificationEndpoint: tt.endpoint,
+		})
+		if err != tt.err {
+			t.Fatalf("%q: expected %v, got %v", tt.endpoint, tt.err, err)
+		}
+	}
+}
+
+func TestCreatePairwise(t *testing.T) {
+	sector := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sector.json":
+			w.Write([]byte(`["https://client.example.com/notify"]`))
+		case "/invalid.json":
+			w.Write([]byte(`{"redirect_uris": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer sector.Close()
+
+	service := NewService(&memoryRepository{map[string]*models.Client{}})
+	service.c = sector.Client()
+
+	tests := []struct {
+		name     string
+		metadata *models.Client
+		err      error
+	}{
+		{"listed", &models.Client{
+			SubjectType:                           models.SubjectTypePairwise,
+			SectorIdentifierURI:                   sector.URL + "/sector.json",
+			BackchannelTokenDeliveryMode:          models.DeliveryModePing,
+			BackchannelClientNotificationEndpoint: "https://client.example.com/notify",
+		}, nil},
+		{"not listed", &models.Client{
+			SubjectType:                           models.SubjectTypePairwise,
+			SectorIdentifierURI:                   sector.URL + "/sector.json",
+			BackchannelTokenDeliveryMode:          models.DeliveryModePing,
+			BackchannelClientNotificationEndpoint: "https://evil.example.com/notify",
+		}, errors.ErrInvalidClientMetadata},
+		{"not an array", &models.Client{SubjectType: models.SubjectTypePairwise, SectorIdentifierURI: sector.URL + "/invalid.json"}, errors.ErrInvalidClientMetadata},
+		{"unreachable", &models.Client{SubjectType: models.SubjectTypePairwise, SectorIdentifierURI: sector.URL + "/missing.json"}, errors.ErrInvalidClientMetadata},
+		{"missing sector", &models.Client{SubjectType: models.SubjectTypePairwise}, errors.ErrInvalidClientMetadata},
+		{"plain http", &models.Client{SubjectType: models.SubjectTypePairwise, SectorIdentifierURI: "http://client.example.com/sector.json"}, errors.ErrInvalidClientMetadata},
+		{"unknown type", &models.Client{SubjectType: "secret"}, errors.ErrInvalidClientMetadata},
+	}
+
+	for _, tt := range tests {
+		_, err := service.Create(context.Background(), tt.metadata)
+		if err != tt.err {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
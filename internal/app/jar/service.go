@@ -0,0 +1,142 @@
+package jar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"oauth/internal/errors"
+	"oauth/internal/models"
+	"oauth/pkg/jwt"
+	"strconv"
+	"time"
+)
+
+// maxRequestObjectSize limits how much is read from a request_uri
+const maxRequestObjectSize = 64 << 10
+
+// registered claims of a request object that are not authorization request parameters
+var registeredClaims = map[string]bool{
+	"iss": true,
+	"aud": true,
+	"exp": true,
+	"iat": true,
+	"nbf": true,
+	"jti": true,
+}
+
+type Service interface {
+	Resolve(ctx context.Context, client *models.Client, params url.Values) (url.Values, error)
+}
+
+type jarService struct {
+	issuer string
+	c      *http.Client
+}
+
+// NewService returns a Service that accepts request objects addressed to issuer
+func NewService(issuer string) *jarService {
+	return &jarService{issuer, &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Resolve returns the authorization request parameters for client. When params
+// carries a request or request_uri, only the parameters inside the verified
+// request object are used.
+func (js *jarService) Resolve(ctx context.Context, client *models.Client, params url.Values) (url.Values, error) {
+	request := params.Get("request")
+	requestURI := params.Get("request_uri")
+
+	switch {
+	case request != "" && requestURI != "":
+		return nil, errors.ErrInvalidRequest
+	case request == "" && requestURI == "":
+		if client.RequireSignedRequestObject {
+			return nil, errors.ErrInvalidRequestObject
+		}
+		return params, nil
+	case requestURI != "":
+		var err error
+		request, err = js.fetch(ctx, client, requestURI)
+		if err != nil {
+			fmt.Println(err)
+			return nil, errors.ErrInvalidRequestURI
+		}
+	}
+
+	keys, err := jwt.ParseJWKS([]byte(client.JWKS))
+	if err != nil {
+		return nil, errors.ErrInvalidRequestObject
+	}
+
+	claims, err := jwt.NewRequestObjectValidator(keys, client.ID, js.issuer).Validate(request)
+	if err != nil {
+		fmt.Println(err)
+		return nil, errors.ErrInvalidRequestObject
+	}
+
+	return claimsToParams(claims), nil
+}
+
+// fetch retrieves a request object from one of the client's registered request_uris
+func (js *jarService) fetch(ctx context.Context, client *models.Client, requestURI string) (string, error) {
+	registered := false
+	for _, uri := range client.RequestURIs {
+		if uri == requestURI {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return "", fmt.Errorf("request_uri is not registered: %s", requestURI)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURI, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/oauth-authz-req+jwt")
+
+	resp, err := js.c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch request_uri: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to fetch request_uri: unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestObjectSize))
+	if err != nil {
+		return "", fmt.Errorf("unable to read request_uri: %s", err)
+	}
+
+	return string(body), nil
+}
+
+func claimsToParams(claims map[string]interface{}) url.Values {
+	params := url.Values{}
+	for name, value := range claims {
+		if registeredClaims[name] {
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			params.Set(name, v)
+		case float64:
+			params.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			params.Set(name, strconv.FormatBool(v))
+		default:
+			b, err := json.Marshal(v)
+			if err == nil {
+				params.Set(name, string(b))
+			}
+		}
+	}
+
+	return params
+}
@@ -0,0 +1,111 @@
+package jar
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"oauth/internal/errors"
+	"oauth/internal/models"
+	pkgjwt "oauth/pkg/jwt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func testClient(t *testing.T) (*ecdsa.PrivateKey, *models.Client) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	jwk, err := pkgjwt.NewJWK(&privateKey.PublicKey, "", "ES256")
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+	keys, err := json.Marshal(pkgjwt.JWKS{Keys: []pkgjwt.JWK{*jwk}})
+	if err != nil {
+		t.Fatalf("Failed to marshal key set: %s", err)
+	}
+
+	return privateKey, &models.Client{ID: "client", JWKS: string(keys)}
+}
+
+func signedRequest(t *testing.T, key *ecdsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":        "client",
+		"aud":        "https://issuer",
+		"exp":        time.Now().Add(time.Minute).Unix(),
+		"scope":      "openid",
+		"login_hint": "signed-user",
+	})
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign request object: %s", err)
+	}
+	return s
+}
+
+func TestResolveRequest(t *testing.T) {
+	key, client := testClient(t)
+	service := NewService("https://issuer")
+
+	params, err := service.Resolve(context.Background(), client, url.Values{
+		"request":    {signedRequest(t, key)},
+		"login_hint": {"tampered-user"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to resolve request: %s", err)
+	}
+
+	if params.Get("login_hint") != "signed-user" || params.Get("scope") != "openid" {
+		t.Fatalf("Expected parameters from the request object, got %v", params)
+	}
+}
+
+func TestResolveRequestURI(t *testing.T) {
+	key, client := testClient(t)
+	request := signedRequest(t, key)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/oauth-authz-req+jwt")
+		w.Write([]byte(request))
+	}))
+	defer srv.Close()
+	client.RequestURIs = []string{srv.URL + "/request"}
+	service := NewService("https://issuer")
+
+	params, err := service.Resolve(context.Background(), client, url.Values{"request_uri": {srv.URL + "/request"}})
+	if err != nil {
+		t.Fatalf("Failed to resolve request_uri: %s", err)
+	}
+	if params.Get("login_hint") != "signed-user" {
+		t.Fatalf("Expected parameters from the request object, got %v", params)
+	}
+
+	_, err = service.Resolve(context.Background(), client, url.Values{"request_uri": {srv.URL + "/other"}})
+	if err != errors.ErrInvalidRequestURI {
+		t.Fatalf("Expected unregistered request_uri to be rejected, got %v", err)
+	}
+}
+
+func TestRequireSignedRequestObject(t *testing.T) {
+	_, client := testClient(t)
+	service := NewService("https://issuer")
+	plain := url.Values{"scope": {"openid"}, "login_hint": {"user"}}
+
+	_, err := service.Resolve(context.Background(), client, plain)
+	if err != nil {
+		t.Fatalf("Expected plain parameters to be accepted, got %v", err)
+	}
+
+	client.RequireSignedRequestObject = true
+	_, err = service.Resolve(context.Background(), client, plain)
+	if err != errors.ErrInvalidRequestObject {
+		t.Fatalf("Expected plain parameters to be rejected, got %v", err)
+	}
+}
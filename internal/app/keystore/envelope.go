@@ -0,0 +1,111 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+)
+
+// envelope encrypts private keys with a fresh data encryption key each, and
+// the data encryption keys with the key encryption key from config. The kid is
+// bound to both as additional data so rows cannot be swapped.
+type envelope struct {
+	kek cipher.AEAD
+}
+
+func newEnvelope(kek []byte) (*envelope, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("key encryption key must be 32 bytes, got %d", len(kek))
+	}
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{aead}, nil
+}
+
+// seal returns the encrypted PKCS #8 key and its encrypted data encryption key
+func (e *envelope) seal(kid string, private crypto.Signer) ([]byte, []byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to encode key %s: %s", kid, err)
+	}
+
+	dek := make([]byte, 32)
+	_, err = rand.Read(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encryptedKey, err := encrypt(aead, der, kid)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptedDEK, err := encrypt(e.kek, dek, kid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encryptedKey, encryptedDEK, nil
+}
+
+// open decrypts a key sealed by seal
+func (e *envelope) open(kid string, encryptedKey []byte, encryptedDEK []byte) (crypto.Signer, error) {
+	dek, err := decrypt(e.kek, encryptedDEK, kid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt key %s, is the key encryption key correct: %s", kid, err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	der, err := decrypt(aead, encryptedKey, kid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt key %s: %s", kid, err)
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse key %s: %s", kid, err)
+	}
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type: %T", k)
+	}
+
+	return signer, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt returns the nonce followed by the ciphertext
+func encrypt(aead cipher.AEAD, plaintext []byte, kid string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(kid)), nil
+}
+
+func decrypt(aead cipher.AEAD, ciphertext []byte, kid string) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(kid))
+}
@@ -0,0 +1,88 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"oauth/pkg/key"
+	"testing"
+)
+
+func testEnvelope(t *testing.T, b byte) *envelope {
+	e, err := newEnvelope(bytes.Repeat([]byte{b}, 32))
+	if err != nil {
+		t.Fatalf("Failed to create envelope: %s", err)
+	}
+	return e
+}
+
+func TestEnvelope(t *testing.T) {
+	e := testEnvelope(t, 1)
+
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		private, err := key.Generate(alg)
+		if err != nil {
+			t.Fatalf("%s: failed to generate key: %s", alg, err)
+		}
+
+		encryptedKey, encryptedDEK, err := e.seal("kid", private)
+		if err != nil {
+			t.Fatalf("%s: failed to seal key: %s", alg, err)
+		}
+
+		opened, err := e.open("kid", encryptedKey, encryptedDEK)
+		if err != nil {
+			t.Fatalf("%s: failed to open key: %s", alg, err)
+		}
+		if !opened.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(private.Public()) {
+			t.Fatalf("%s: opened key does not match", alg)
+		}
+	}
+}
+
+func TestEnvelopeRejectsTampering(t *testing.T) {
+	e := testEnvelope(t, 1)
+	private, err := key.Generate("ES256")
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	encryptedKey, encryptedDEK, err := e.seal("kid", private)
+	if err != nil {
+		t.Fatalf("Failed to seal key: %s", err)
+	}
+	otherKey, otherDEK, err := e.seal("other", private)
+	if err != nil {
+		t.Fatalf("Failed to seal key: %s", err)
+	}
+
+	flipped := append([]byte{}, encryptedKey...)
+	flipped[len(flipped)-1] ^= 1
+
+	tests := []struct {
+		name         string
+		e            *envelope
+		kid          string
+		encryptedKey []byte
+		encryptedDEK []byte
+	}{
+		{"wrong key encryption key", testEnvelope(t, 2), "kid", encryptedKey, encryptedDEK},
+		{"wrong kid", e, "other", encryptedKey, encryptedDEK},
+		{"swapped data key", e, "kid", encryptedKey, otherDEK},
+		{"swapped key", e, "kid", otherKey, encryptedDEK},
+		{"modified key", e, "kid", flipped, encryptedDEK},
+		{"truncated", e, "kid", encryptedKey[:4], encryptedDEK},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.e.open(tt.kid, tt.encryptedKey, tt.encryptedDEK)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestNewEnvelopeKeySize(t *testing.T) {
+	_, err := newEnvelope(make([]byte, 16))
+	if err == nil {
+		t.Fatal("Expected error for a short key encryption key, got nil")
+	}
+}
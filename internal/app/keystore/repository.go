@@ -0,0 +1,259 @@
+package keystore
+
+import (
+	"context"
+	"fmt"
+	"oauth/pkg/keyring"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// changeChannel is notified whenever the stored keys change
+const changeChannel = "signing_key_changed"
+
+// bootstrapLockID serializes replicas creating the first key
+const bootstrapLockID int64 = 0x6f61757468 + 1
+
+type keyRepository struct {
+	pool     *pgxpool.Pool
+	envelope *envelope
+	cancel   context.CancelFunc
+}
+
+// NewRepository returns a keyring.Store that keeps signing keys in Postgres,
+// encrypted under kek
+func NewRepository(pool *pgxpool.Pool, kek []byte) (*keyRepository, error) {
+	e, err := newEnvelope(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &keyRepository{pool: pool, envelope: e, cancel: func() {}}
+	err = repo.initTable()
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func (kr *keyRepository) Close() {
+	kr.cancel()
+}
+
+func (kr *keyRepository) initTable() error {
+	_, err := kr.pool.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS signing_key (
+	kid				TEXT		PRIMARY KEY,
+	alg				TEXT		NOT NULL,
+	state			TEXT		NOT NULL,
+	created_at		TIMESTAMPTZ	NOT NULL,
+	activated_at	TIMESTAMPTZ,
+	not_before		TIMESTAMPTZ,
+	not_after		TIMESTAMPTZ,
+	encrypted_key	BYTEA		NOT NULL,
+	encrypted_dek	BYTEA		NOT NULL
+	);
+	`)
+	return err
+}
+
+// Load decrypts every stored key
+func (kr *keyRepository) Load(ctx context.Context) ([]*keyring.Key, error) {
+	rows, err := kr.pool.Query(ctx, `
+	SELECT kid, alg, state, created_at, activated_at, not_before, not_after, encrypted_key, encrypted_dek
+	FROM public.signing_key ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*keyring.Key
+	for rows.Next() {
+		var kid, alg, state string
+		var createdAt time.Time
+		var activatedAt, notBefore, notAfter *time.Time
+		var encryptedKey, encryptedDEK []byte
+		err := rows.Scan(&kid, &alg, &state, &createdAt, &activatedAt, &notBefore, &notAfter, &encryptedKey, &encryptedDEK)
+		if err != nil {
+			return nil, err
+		}
+
+		private, err := kr.envelope.open(kid, encryptedKey, encryptedDEK)
+		if err != nil {
+			return nil, err
+		}
+		k, err := keyring.NewKey(private, alg, state)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %s", kid, err)
+		}
+		k.ID = kid
+		k.CreatedAt = createdAt
+		k.ActivatedAt = fromNull(activatedAt)
+		k.NotBefore = fromNull(notBefore)
+		k.NotAfter = fromNull(notAfter)
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// Save replaces the stored keys with keys and notifies every replica. The key
+// material of stored keys is never rewritten, only their state and windows.
+func (kr *keyRepository) Save(ctx context.Context, keys []*keyring.Key) error {
+	tx, err := kr.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = kr.save(ctx, tx, keys)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (kr *keyRepository) save(ctx context.Context, tx pgx.Tx, keys []*keyring.Key) error {
+	kids := make([]string, 0, len(keys))
+	for _, k := range keys {
+		kids = append(kids, k.ID)
+
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM public.signing_key WHERE kid = $1)", k.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			_, err = tx.Exec(ctx, `
+			UPDATE signing_key SET state = $2, activated_at = $3, not_before = $4, not_after = $5
+			WHERE kid = $1
+			`, k.ID, k.State, toNull(k.ActivatedAt), toNull(k.NotBefore), toNull(k.NotAfter))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		encryptedKey, encryptedDEK, err := kr.envelope.seal(k.ID, k.Signer)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+		INSERT INTO signing_key (kid, alg, state, created_at, activated_at, not_before, not_after, encrypted_key, encrypted_dek)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, k.ID, k.Alg, k.State, k.CreatedAt, toNull(k.ActivatedAt), toNull(k.NotBefore), toNull(k.NotAfter), encryptedKey, encryptedDEK)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(ctx, "DELETE FROM signing_key WHERE NOT (kid = ANY($1))", kids)
+	if err != nil {
+		return err
+	}
+
+	// delivered to listeners once the transaction commits
+	_, err = tx.Exec(ctx, "SELECT pg_notify($1, '')", changeChannel)
+	return err
+}
+
+// Bootstrap stores the key returned by generate as the active key when no key
+// is stored yet. Replicas starting together wait on each other, so only one
+// key is created.
+func (kr *keyRepository) Bootstrap(ctx context.Context, generate func() (*keyring.Key, error)) error {
+	tx, err := kr.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", bootstrapLockID)
+	if err != nil {
+		return err
+	}
+	var count int
+	err = tx.QueryRow(ctx, "SELECT count(*) FROM public.signing_key").Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	k, err := generate()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("keystore: no signing keys stored, created key %s\n", k.ID)
+	err = kr.save(ctx, tx, []*keyring.Key{k})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Watch calls onChange whenever any replica saves keys, and after every
+// reconnect in case a change was missed, until Close is called
+func (kr *keyRepository) Watch(onChange func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	kr.cancel = cancel
+
+	go func() {
+		for {
+			err := kr.listen(ctx, onChange)
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("keystore: lost change notifications, retrying: %s\n", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+}
+
+func (kr *keyRepository) listen(ctx context.Context, onChange func(ctx context.Context)) error {
+	conn, err := kr.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		conn.Exec(context.Background(), "UNLISTEN "+changeChannel)
+		conn.Release()
+	}()
+
+	_, err = conn.Exec(ctx, "LISTEN "+changeChannel)
+	if err != nil {
+		return err
+	}
+	onChange(ctx)
+
+	for {
+		_, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		onChange(ctx)
+	}
+}
+
+func toNull(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func fromNull(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
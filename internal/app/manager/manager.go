@@ -3,28 +3,49 @@ package manager
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"oauth/internal/app/ciba"
 	"oauth/internal/app/client"
+	"oauth/internal/app/jar"
+	"oauth/internal/app/saml"
+	"oauth/internal/app/subject"
 	"oauth/internal/app/token"
 	"oauth/internal/errors"
 	"oauth/internal/models"
-	"oauth/pkg/rsa"
+	"oauth/pkg/jwt"
+	"oauth/pkg/keyring"
+	"strconv"
+	"time"
 )
 
 // Manager orchestrates client and token services
 type Manager struct {
-	clientService client.Service
-	tokenService  token.Service
+	clientService  client.Service
+	tokenService   token.Service
+	cibaService    ciba.Service
+	jarService     jar.Service
+	subjectService subject.Service
+	samlService    saml.Service
 }
 
 // NewManager -
-func NewManager(cs client.Service, ts token.Service) *Manager {
-	return &Manager{cs, ts}
+func NewManager(cs client.Service, ts token.Service, bs ciba.Service, js jar.Service, ss subject.Service, as saml.Service) *Manager {
+	return &Manager{cs, ts, bs, js, ss, as}
 }
 
 // RegisterClient handles client registration
-func (m *Manager) RegisterClient(ctx context.Context) (*models.Client, error) {
-	client, err := m.clientService.Create(ctx)
-	if err != nil {
+func (m *Manager) RegisterClient(ctx context.Context, metadata *models.Client) (*models.Client, error) {
+	if metadata.BackchannelTokenDeliveryMode != "" && !m.cibaService.Enabled() {
+		return nil, errors.ErrInvalidClientMetadata
+	}
+	if metadata.SubjectType == models.SubjectTypePairwise && !m.subjectService.Salted() {
+		return nil, errors.ErrInvalidClientMetadata
+	}
+
+	client, err := m.clientService.Create(ctx, metadata)
+	if err == errors.ErrInvalidClientMetadata {
+		return nil, err
+	} else if err != nil {
 		fmt.Println(err)
 		return nil, errors.ErrInternalServer
 	}
@@ -33,14 +54,161 @@ func (m *Manager) RegisterClient(ctx context.Context) (*models.Client, error) {
 }
 
 // GenerateToken handles token generation
-func (m *Manager) GenerateToken(ctx context.Context, reqClient *models.Client) (*models.Token, error) {
-	client, err := m.clientService.GetByID(ctx, reqClient.ID)
-	if err != nil || reqClient.Secret != client.Secret {
-		return nil, errors.ErrInvalidClient
+func (m *Manager) GenerateToken(ctx context.Context, reqClient *models.Client, resource string) (*models.Token, error) {
+	client, err := m.authenticateClient(ctx, reqClient)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := m.tokenService.Create(ctx, client, nil, resource)
+	if err == errors.ErrInvalidTarget {
+		return nil, err
+	} else if err != nil {
+		fmt.Println(err)
+		return nil, errors.ErrInternalServer
+	}
+
+	return token, nil
+}
+
+// BackchannelAuthorize starts a CIBA flow for an authenticated client. The
+// request parameters may be passed in a signed request object.
+func (m *Manager) BackchannelAuthorize(ctx context.Context, reqClient *models.Client, params url.Values) (*models.BackchannelAuthRequest, error) {
+	client, err := m.authenticateClient(ctx, reqClient)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err = m.jarService.Resolve(ctx, client, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiry time.Duration
+	if v := params.Get("requested_expiry"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return nil, errors.ErrInvalidRequest
+		}
+		expiry = time.Duration(seconds) * time.Second
+	}
+
+	authReq, err := m.cibaService.Create(ctx, client, &models.BackchannelAuthRequest{
+		Scope:                   params.Get("scope"),
+		LoginHint:               params.Get("login_hint"),
+		BindingMessage:          params.Get("binding_message"),
+		ACRValues:               params.Get("acr_values"),
+		ClientNotificationToken: params.Get("client_notification_token"),
+	}, expiry)
+	if err == errors.ErrUnauthorizedClient || err == errors.ErrInvalidRequest {
+		return nil, err
+	} else if err != nil {
+		fmt.Println(err)
+		return nil, errors.ErrInternalServer
+	}
+
+	return authReq, nil
+}
+
+// ResolveBackchannelAuth records the decision made on the user's authentication
+// device and pings the client when it uses ping mode
+func (m *Manager) ResolveBackchannelAuth(ctx context.Context, id string, callbackToken string, approved bool, acr string, amr []string) error {
+	authReq, err := m.cibaService.Resolve(ctx, id, callbackToken, approved, acr, amr)
+	if err == errors.ErrInvalidGrant || err == errors.ErrExpiredToken || err == errors.ErrInsufficientAuth {
+		return err
+	} else if err != nil {
+		fmt.Println(err)
+		return errors.ErrInternalServer
+	}
+
+	client, err := m.clientService.GetByID(ctx, authReq.ClientID)
+	if err != nil {
+		fmt.Println(err)
+		return errors.ErrInternalServer
 	}
 
-	token, err := m.tokenService.Create(ctx, client)
+	if client.BackchannelTokenDeliveryMode == models.DeliveryModePing {
+		err = m.cibaService.Ping(ctx, client, authReq)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// GenerateBackchannelToken handles the CIBA grant
+func (m *Manager) GenerateBackchannelToken(ctx context.Context, reqClient *models.Client, authReqID string, resource string) (*models.Token, error) {
+	client, err := m.authenticateClient(ctx, reqClient)
+	if err != nil {
+		return nil, err
+	}
+	if client.BackchannelTokenDeliveryMode == "" {
+		return nil, errors.ErrUnauthorizedClient
+	}
+
+	authReq, err := m.cibaService.Redeem(ctx, client, authReqID)
+	switch err {
+	case nil:
+	case errors.ErrInvalidGrant, errors.ErrExpiredToken, errors.ErrAccessDenied,
+		errors.ErrAuthorizationPending, errors.ErrSlowDown:
+		return nil, err
+	default:
+		fmt.Println(err)
+		return nil, errors.ErrInternalServer
+	}
+
+	token, err := m.tokenService.Create(ctx, client, &models.Authentication{
+		Subject:  m.subjectService.Subject(client, authReq.LoginHint),
+		ACR:      authReq.ACR,
+		AMR:      authReq.AMR,
+		AuthTime: authReq.AuthTime,
+	}, resource)
+	if err == errors.ErrInvalidTarget {
+		return nil, err
+	} else if err != nil {
+		fmt.Println(err)
+		return nil, errors.ErrInternalServer
+	}
+
+	return token, nil
+}
+
+// GenerateSAMLToken handles the SAML 2.0 bearer assertion grant. An authenticated
+// client receives a token for the user named by the assertion, otherwise the
+// assertion subject must be a registered client acting on its own behalf that
+// the identity provider is allowed to assert.
+func (m *Manager) GenerateSAMLToken(ctx context.Context, reqClient *models.Client, assertion string, resource string) (*models.Token, error) {
+	a, err := m.samlService.Verify(ctx, assertion)
 	if err != nil {
+		fmt.Println(err)
+		return nil, errors.ErrInvalidGrant
+	}
+
+	var client *models.Client
+	var auth *models.Authentication
+	if reqClient != nil {
+		client, err = m.authenticateClient(ctx, reqClient)
+		if err != nil {
+			return nil, err
+		}
+		auth = &models.Authentication{Subject: m.subjectService.Subject(client, a.Subject)}
+	} else {
+		clientID, err := m.samlService.AssertedClient(a)
+		if err != nil {
+			fmt.Println(err)
+			return nil, errors.ErrInvalidGrant
+		}
+		client, err = m.clientService.GetByID(ctx, clientID)
+		if err != nil {
+			return nil, errors.ErrInvalidGrant
+		}
+	}
+
+	token, err := m.tokenService.Create(ctx, client, auth, resource)
+	if err == errors.ErrInvalidTarget {
+		return nil, err
+	} else if err != nil {
 		fmt.Println(err)
 		return nil, errors.ErrInternalServer
 	}
@@ -57,6 +225,26 @@ func (m *Manager) ValidateToken(ctx context.Context, reqToken string) bool {
 	return true
 }
 
-func (m *Manager) GetPublicKey() ([]byte, error) {
-	return rsa.PublicBytes(m.tokenService.Public())
+// GetPublicKey returns the key that currently signs tokens
+func (m *Manager) GetPublicKey() (*keyring.Key, error) {
+	return m.tokenService.Keys().Active()
+}
+
+// GetPublicKeys returns every key that currently verifies tokens
+func (m *Manager) GetPublicKeys() []*keyring.Key {
+	return m.tokenService.Keys().Keys()
+}
+
+// GetJWKS returns every key that currently verifies tokens as a JWK set
+func (m *Manager) GetJWKS() (*jwt.JWKS, error) {
+	return m.tokenService.Keys().JWKS()
+}
+
+func (m *Manager) authenticateClient(ctx context.Context, reqClient *models.Client) (*models.Client, error) {
+	client, err := m.clientService.GetByID(ctx, reqClient.ID)
+	if err != nil || reqClient.Secret != client.Secret {
+		return nil, errors.ErrInvalidClient
+	}
+
+	return client, nil
 }
@@ -0,0 +1,125 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"oauth/internal/errors"
+	"oauth/pkg/jwt"
+	"os"
+	"path/filepath"
+)
+
+// Server is a resource server access tokens can be issued for. Tokens are
+// encrypted to EncryptionKey when it is set, so only the resource server can
+// read their claims.
+type Server struct {
+	Audience      string
+	EncryptionAlg string
+	EncryptionEnc string
+	EncryptionKey *jwt.JWK
+}
+
+// registration is the JSON file a resource server is registered with
+type registration struct {
+	Audience      string          `json:"audience"`
+	EncryptionAlg string          `json:"access_token_encrypted_response_alg"`
+	EncryptionEnc string          `json:"access_token_encrypted_response_enc"`
+	JWKS          json.RawMessage `json:"jwks"`
+}
+
+type Service interface {
+	Lookup(audience string) (*Server, error)
+}
+
+type resourceService struct {
+	servers map[string]*Server
+}
+
+func NewService(servers map[string]*Server) *resourceService {
+	return &resourceService{servers}
+}
+
+// Lookup returns the resource server registered for audience
+func (rs *resourceService) Lookup(audience string) (*Server, error) {
+	s, ok := rs.servers[audience]
+	if !ok {
+		return nil, errors.ErrInvalidTarget
+	}
+	return s, nil
+}
+
+// LoadServers reads every *.json resource server registration in dir and
+// returns the servers keyed by audience
+func LoadServers(dir string) (map[string]*Server, error) {
+	servers := make(map[string]*Server)
+	if dir == "" {
+		return servers, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read resource server file: %s", err)
+		}
+
+		s, err := parseRegistration(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse resource server file %s: %s", f, err)
+		}
+		if _, ok := servers[s.Audience]; ok {
+			return nil, fmt.Errorf("resource server %s is registered twice", s.Audience)
+		}
+		servers[s.Audience] = s
+	}
+
+	return servers, nil
+}
+
+func parseRegistration(data []byte) (*Server, error) {
+	var r registration
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Audience == "" {
+		return nil, fmt.Errorf("missing audience")
+	}
+
+	s := &Server{Audience: r.Audience}
+	if r.EncryptionAlg == "" {
+		if r.EncryptionEnc != "" {
+			return nil, fmt.Errorf("access_token_encrypted_response_enc requires an alg")
+		}
+		return s, nil
+	}
+
+	s.EncryptionAlg = r.EncryptionAlg
+	s.EncryptionEnc = r.EncryptionEnc
+	if s.EncryptionEnc == "" {
+		s.EncryptionEnc = jwt.EncA256GCM
+	}
+
+	keys, err := jwt.ParseJWKS(r.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	s.EncryptionKey, err = keys.EncryptionKey(s.EncryptionAlg)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.EncryptionKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	err = jwt.CheckEncryptionAlgorithm(key, s.EncryptionAlg, s.EncryptionEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,108 @@
+package resource
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"oauth/internal/errors"
+	"oauth/pkg/jwt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRegistration(t *testing.T, dir string, name string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode registration: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, name), b, 0600)
+	if err != nil {
+		t.Fatalf("Failed to write registration: %s", err)
+	}
+}
+
+func TestLoadServers(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	sig, err := jwt.NewJWK(&key.PublicKey, "sig-key", "ES256")
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+	enc, err := jwt.NewJWK(&key.PublicKey, "enc-key", "")
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+	enc.Use = "enc"
+	noUse := *enc
+	noUse.Use = ""
+
+	dir := t.TempDir()
+	writeRegistration(t, dir, "api.json", map[string]interface{}{
+		"audience":                            "https://api.example.com",
+		"access_token_encrypted_response_alg": jwt.AlgECDHES,
+		"jwks":                                jwt.JWKS{Keys: []jwt.JWK{*sig, *enc}},
+	})
+	writeRegistration(t, dir, "plain.json", map[string]interface{}{
+		"audience": "https://plain.example.com",
+	})
+
+	servers, err := LoadServers(dir)
+	if err != nil {
+		t.Fatalf("Failed to load resource servers: %s", err)
+	}
+	rs := NewService(servers)
+
+	api, err := rs.Lookup("https://api.example.com")
+	if err != nil {
+		t.Fatalf("Failed to look up resource server: %s", err)
+	}
+	if api.EncryptionKey == nil || api.EncryptionKey.Kid != "enc-key" {
+		t.Fatalf("Expected the enc key to be selected, got %+v", api.EncryptionKey)
+	}
+	if api.EncryptionEnc != jwt.EncA256GCM {
+		t.Fatalf("Expected enc to default to %s, got %s", jwt.EncA256GCM, api.EncryptionEnc)
+	}
+
+	plain, err := rs.Lookup("https://plain.example.com")
+	if err != nil {
+		t.Fatalf("Failed to look up resource server: %s", err)
+	}
+	if plain.EncryptionKey != nil {
+		t.Fatalf("Expected no encryption key, got %+v", plain.EncryptionKey)
+	}
+
+	_, err = rs.Lookup("https://unknown.example.com")
+	if err != errors.ErrInvalidTarget {
+		t.Fatalf("Expected %s, got %v", errors.ErrInvalidTarget, err)
+	}
+
+	invalid := []map[string]interface{}{
+		{"access_token_encrypted_response_alg": jwt.AlgECDHES},
+		{"audience": "a", "access_token_encrypted_response_enc": jwt.EncA256GCM},
+		// signing keys and keys without use are never encryption keys
+		{"audience": "a", "access_token_encrypted_response_alg": jwt.AlgECDHES, "jwks": jwt.JWKS{Keys: []jwt.JWK{*sig}}},
+		{"audience": "a", "access_token_encrypted_response_alg": jwt.AlgECDHES, "jwks": jwt.JWKS{Keys: []jwt.JWK{noUse}}},
+		{"audience": "a", "access_token_encrypted_response_alg": jwt.AlgRSAOAEP256, "jwks": jwt.JWKS{Keys: []jwt.JWK{*enc}}},
+		{"audience": "a", "access_token_encrypted_response_alg": jwt.AlgECDHES, "access_token_encrypted_response_enc": "A128CBC-HS256", "jwks": jwt.JWKS{Keys: []jwt.JWK{*enc}}},
+	}
+	for i, reg := range invalid {
+		dir := t.TempDir()
+		writeRegistration(t, dir, "rs.json", reg)
+		_, err := LoadServers(dir)
+		if err == nil {
+			t.Fatalf("%d: expected registration to be rejected, got nil", i)
+		}
+	}
+
+	writeRegistration(t, dir, "duplicate.json", map[string]interface{}{
+		"audience": "https://plain.example.com",
+	})
+	_, err = LoadServers(dir)
+	if err == nil {
+		t.Fatal("Expected duplicate audience to be rejected, got nil")
+	}
+}
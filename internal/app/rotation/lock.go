@@ -0,0 +1,49 @@
+package rotation
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// rotationLockID identifies the advisory lock held while rotating keys
+const rotationLockID int64 = 0x6f61757468
+
+// Locker makes sure only one replica rotates keys at a time
+type Locker interface {
+	// TryLock runs fn while holding the lock. It reports false without running
+	// fn when another replica holds the lock.
+	TryLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+}
+
+type advisoryLocker struct {
+	pool *pgxpool.Pool
+}
+
+// NewAdvisoryLocker returns a Locker backed by a Postgres advisory lock
+func NewAdvisoryLocker(pool *pgxpool.Pool) *advisoryLocker {
+	return &advisoryLocker{pool}
+}
+
+// TryLock takes a transaction level advisory lock, so the lock is released
+// when fn returns even if the connection is lost
+func (al *advisoryLocker) TryLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	tx, err := al.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	err = tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", rotationLockID).Scan(&locked)
+	if err != nil || !locked {
+		return false, err
+	}
+
+	err = fn(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,174 @@
+package rotation
+
+import (
+	"context"
+	"fmt"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+	"sync"
+	"time"
+)
+
+// Policy controls when signing keys are rotated
+type Policy struct {
+	// Interval is how long a key stays the active signing key
+	Interval time.Duration
+	// LeadTime is how long a new key is published before it starts signing
+	LeadTime time.Duration
+	// Retention is how long a replaced key keeps verifying tokens, at least
+	// the longest token lifetime plus a grace period
+	Retention time.Duration
+	// Algorithm is the signing algorithm of generated keys, the algorithm of
+	// the active key when empty
+	Algorithm string
+}
+
+// Rotator periodically rotates the signing keys of a keyring
+type Rotator struct {
+	ring   *keyring.Keyring
+	store  keyring.Store
+	locker Locker
+	policy Policy
+	ticker time.Ticker
+	done   chan bool
+	once   sync.Once
+}
+
+// NewRotator returns a Rotator that keeps ring in sync with store and rotates
+// the stored keys according to policy
+func NewRotator(ring *keyring.Keyring, store keyring.Store, locker Locker, policy Policy) *Rotator {
+	return &Rotator{ring: ring, store: store, locker: locker, policy: policy, ticker: *time.NewTicker(5 * time.Minute), done: make(chan bool)}
+}
+
+// Start runs rotation checks in the background until Close is called
+func (r *Rotator) Start() {
+	go func() {
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-r.ticker.C:
+				err := r.Rotate(context.Background(), time.Now())
+				if err != nil {
+					fmt.Println(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the rotation checks. It does not block, whether or not Start
+// was called, and may be called more than once.
+func (r *Rotator) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+// Rotate applies the rotation step due at now when this replica wins the lock,
+// then reloads the keyring from the store so rotations made by other replicas
+// are picked up as well
+func (r *Rotator) Rotate(ctx context.Context, now time.Time) error {
+	keys, err := r.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load keys: %s", err)
+	}
+
+	_, err = r.locker.TryLock(ctx, func(ctx context.Context) error {
+		// reload under the lock in case another replica rotated in the meantime
+		keys, err = r.store.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to load keys: %s", err)
+		}
+
+		next, changed, err := plan(keys, now, r.policy, r.generate)
+		if err != nil || !changed {
+			return err
+		}
+
+		err = r.store.Save(ctx, next)
+		if err != nil {
+			return fmt.Errorf("unable to save keys: %s", err)
+		}
+		keys = next
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.ring.Set(keys)
+}
+
+func (r *Rotator) generate(alg string) (*keyring.Key, error) {
+	if r.policy.Algorithm != "" {
+		alg = r.policy.Algorithm
+	}
+	private, err := key.Generate(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyring.NewKey(private, alg, keyring.StatePending)
+}
+
+// plan returns the keys after the rotation step due at now and whether any
+// key changed. A new key is published LeadTime before the active key has
+// served for Interval, promoted once that time is up, and the replaced key is
+// dropped after Retention.
+func plan(keys []*keyring.Key, now time.Time, p Policy, generate func(alg string) (*keyring.Key, error)) ([]*keyring.Key, bool, error) {
+	changed := false
+	next := make([]*keyring.Key, 0, len(keys)+1)
+	var active, pending *keyring.Key
+	for _, k := range keys {
+		k := *k
+		if k.State == keyring.StateRetired && !k.NotAfter.IsZero() && !now.Before(k.NotAfter) {
+			fmt.Printf("keyring: removing retired key %s\n", k.ID)
+			changed = true
+			continue
+		}
+
+		switch k.State {
+		case keyring.StateActive:
+			active = &k
+		case keyring.StatePending:
+			if pending == nil {
+				pending = &k
+			}
+		}
+		next = append(next, &k)
+	}
+
+	if active == nil {
+		return nil, false, fmt.Errorf("no active key to rotate")
+	}
+
+	activatedAt := active.ActivatedAt
+	if activatedAt.IsZero() {
+		activatedAt = active.CreatedAt
+	}
+	due := activatedAt.Add(p.Interval)
+
+	if pending == nil && !now.Before(due.Add(-p.LeadTime)) {
+		k, err := generate(active.Alg)
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to generate key: %s", err)
+		}
+		k.CreatedAt = now
+		fmt.Printf("keyring: publishing new key %s\n", k.ID)
+		pending = k
+		next = append(next, k)
+		changed = true
+	}
+
+	if !now.Before(due) && !now.Before(pending.CreatedAt.Add(p.LeadTime)) {
+		fmt.Printf("keyring: promoting key %s, retiring key %s\n", pending.ID, active.ID)
+		active.State = keyring.StateRetired
+		active.NotAfter = now.Add(p.Retention)
+		pending.State = keyring.StateActive
+		pending.ActivatedAt = now
+		changed = true
+	}
+
+	return next, changed, nil
+}
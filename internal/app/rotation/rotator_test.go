@@ -0,0 +1,222 @@
+package rotation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	keys []*keyring.Key
+}
+
+func (ms *memoryStore) Load(ctx context.Context) ([]*keyring.Key, error) {
+	keys := make([]*keyring.Key, len(ms.keys))
+	for i, k := range ms.keys {
+		k := *k
+		keys[i] = &k
+	}
+	return keys, nil
+}
+
+func (ms *memoryStore) Save(ctx context.Context, keys []*keyring.Key) error {
+	ms.keys = keys
+	return nil
+}
+
+type fakeLocker struct {
+	held bool
+}
+
+func (fl *fakeLocker) TryLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	if fl.held {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+func setup(t *testing.T, start time.Time) (*keyring.Keyring, *memoryStore, *fakeLocker, *Rotator) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	k, err := keyring.NewKey(private, "", keyring.StateActive)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+	k.CreatedAt = start
+
+	ring, err := keyring.New([]*keyring.Key{k})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+
+	store := &memoryStore{[]*keyring.Key{k}}
+	locker := &fakeLocker{}
+	policy := Policy{Interval: 30 * 24 * time.Hour, LeadTime: 48 * time.Hour, Retention: time.Hour, Algorithm: "RS256"}
+
+	return ring, store, locker, NewRotator(ring, store, locker, policy)
+}
+
+func activeID(t *testing.T, ring *keyring.Keyring) string {
+	k, err := ring.Active()
+	if err != nil {
+		t.Fatalf("Failed to get active key: %s", err)
+	}
+	return k.ID
+}
+
+func TestRotate(t *testing.T) {
+	start := time.Now()
+	ring, store, _, r := setup(t, start)
+	first := activeID(t, ring)
+
+	err := r.Rotate(context.Background(), start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if len(store.keys) != 1 {
+		t.Fatalf("Expected no new key before lead time, got %d keys", len(store.keys))
+	}
+
+	// the new key is published ahead of time without signing
+	err = r.Rotate(context.Background(), start.Add(28*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if len(store.keys) != 2 || store.keys[1].State != keyring.StatePending {
+		t.Fatalf("Expected a pending key to be published")
+	}
+	if activeID(t, ring) != first {
+		t.Fatalf("Expected active key to be unchanged")
+	}
+	if len(ring.Keys()) != 2 {
+		t.Fatalf("Expected pending key in the keyring, got %d keys", len(ring.Keys()))
+	}
+	second := store.keys[1].ID
+
+	// the new key starts signing once the interval is up
+	rotatedAt := start.Add(30 * 24 * time.Hour)
+	err = r.Rotate(context.Background(), rotatedAt)
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if activeID(t, ring) != second {
+		t.Fatalf("Expected key %s to be active", second)
+	}
+	old, err := ring.Lookup(first)
+	if err != nil || old.State != keyring.StateRetired {
+		t.Fatalf("Expected key %s to be retired", first)
+	}
+	if !old.NotAfter.Equal(rotatedAt.Add(time.Hour)) {
+		t.Fatalf("Expected retired key to expire after retention, got %s", old.NotAfter)
+	}
+
+	// the retired key is dropped after retention
+	err = r.Rotate(context.Background(), rotatedAt.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if len(store.keys) != 1 || store.keys[0].ID != second {
+		t.Fatalf("Expected only key %s to remain", second)
+	}
+
+	// the next rotation is counted from activation, not creation
+	err = r.Rotate(context.Background(), rotatedAt.Add(27*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if len(store.keys) != 1 {
+		t.Fatalf("Expected no new key before lead time, got %d keys", len(store.keys))
+	}
+}
+
+func TestRotateLocked(t *testing.T) {
+	start := time.Now()
+	ring, store, locker, r := setup(t, start)
+	locker.held = true
+
+	err := r.Rotate(context.Background(), start.Add(31*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	if len(store.keys) != 1 {
+		t.Fatalf("Expected keys to be unchanged while another replica holds the lock")
+	}
+
+	// keys rotated by another replica are still picked up
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	pending, err := keyring.NewKey(private, "", keyring.StatePending)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+	store.keys = append(store.keys, pending)
+
+	err = r.Rotate(context.Background(), start.Add(31*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	_, err = ring.Lookup(pending.ID)
+	if err != nil {
+		t.Fatalf("Expected keyring to be reloaded from the store")
+	}
+}
+
+func TestRotateActiveAlgorithm(t *testing.T) {
+	private, err := key.Generate("ES256")
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	k, err := keyring.NewKey(private, "", keyring.StateActive)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+	start := time.Now()
+	k.CreatedAt = start
+	ring, err := keyring.New([]*keyring.Key{k})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+	store := &memoryStore{[]*keyring.Key{k}}
+
+	// without SIGNING_ALG new keys keep the algorithm of the active key
+	r := NewRotator(ring, store, &fakeLocker{}, Policy{Interval: time.Hour, Retention: time.Hour})
+	err = r.Rotate(context.Background(), start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to rotate: %s", err)
+	}
+	active, err := ring.Active()
+	if err != nil {
+		t.Fatalf("Failed to get active key: %s", err)
+	}
+	if active.ID == k.ID || active.Alg != "ES256" {
+		t.Fatalf("Expected a new ES256 key to be active, got %s %s", active.ID, active.Alg)
+	}
+}
+
+func TestClose(t *testing.T) {
+	_, _, _, never := setup(t, time.Now())
+	_, _, _, started := setup(t, time.Now())
+	started.Start()
+
+	closed := make(chan bool)
+	go func() {
+		never.Close()
+		started.Close()
+		started.Close()
+		closed <- true
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to return")
+	}
+}
@@ -0,0 +1,123 @@
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IdentityProvider is a SAML identity provider whose assertions are trusted
+type IdentityProvider struct {
+	EntityID     string
+	Certificates []*x509.Certificate
+	// Clients are the clients the identity provider may name as the subject
+	// of an assertion presented without client authentication
+	Clients map[string]bool
+}
+
+type entityDescriptor struct {
+	EntityID         string `xml:"entityID,attr"`
+	IDPSSODescriptor struct {
+		KeyDescriptors []struct {
+			Use         string `xml:"use,attr"`
+			Certificate string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// LoadMetadata reads every *.xml SAML metadata file in dir and returns the
+// identity providers they describe, keyed by entity ID. The clients an
+// identity provider may assert are listed as a JSON array in an optional
+// <name>.clients.json file next to its <name>.xml metadata.
+func LoadMetadata(dir string) (map[string]*IdentityProvider, error) {
+	idps := make(map[string]*IdentityProvider)
+	if dir == "" {
+		return idps, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read metadata file: %s", err)
+		}
+
+		idp, err := parseMetadata(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse metadata file %s: %s", f, err)
+		}
+		idp.Clients, err = loadClients(strings.TrimSuffix(f, ".xml") + ".clients.json")
+		if err != nil {
+			return nil, err
+		}
+		idps[idp.EntityID] = idp
+	}
+
+	return idps, nil
+}
+
+func parseMetadata(data []byte) (*IdentityProvider, error) {
+	var ed entityDescriptor
+	err := xml.Unmarshal(data, &ed)
+	if err != nil {
+		return nil, err
+	}
+	if ed.EntityID == "" {
+		return nil, fmt.Errorf("missing entityID")
+	}
+
+	idp := &IdentityProvider{EntityID: ed.EntityID}
+	for _, kd := range ed.IDPSSODescriptor.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(kd.Certificate), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate encoding: %s", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %s", err)
+		}
+		idp.Certificates = append(idp.Certificates, cert)
+	}
+
+	if len(idp.Certificates) == 0 {
+		return nil, fmt.Errorf("no signing certificate for %s", ed.EntityID)
+	}
+
+	return idp, nil
+}
+
+// loadClients reads a JSON array of client IDs from path. A missing file
+// allows no clients.
+func loadClients(path string) (map[string]bool, error) {
+	clients := make(map[string]bool)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return clients, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read clients file: %s", err)
+	}
+
+	var ids []string
+	err = json.Unmarshal(data, &ids)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse clients file %s: %s", path, err)
+	}
+	for _, id := range ids {
+		clients[id] = true
+	}
+
+	return clients, nil
+}
@@ -0,0 +1,88 @@
+package saml
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Repository records the assertions that have been used, so each one is
+// accepted only once
+type Repository interface {
+	// Use records the assertion issuer and id until expiresAt and reports
+	// whether it was recorded for the first time
+	Use(ctx context.Context, issuer string, id string, expiresAt time.Time) (bool, error)
+}
+
+type samlRepository struct {
+	pool   *pgxpool.Pool
+	ticker time.Ticker
+	done   chan bool
+	once   sync.Once
+}
+
+func NewRepository(pool *pgxpool.Pool) (*samlRepository, error) {
+	repo := &samlRepository{pool: pool, ticker: *time.NewTicker(5 * time.Minute), done: make(chan bool)}
+	err := repo.initTable()
+	if err != nil {
+		return nil, err
+	}
+	go repo.gc()
+
+	return repo, nil
+}
+
+// Close stops the expired row cleanup. It does not block and may be called
+// more than once.
+func (sr *samlRepository) Close() {
+	sr.once.Do(func() {
+		close(sr.done)
+	})
+}
+
+func (sr *samlRepository) initTable() error {
+	_, err := sr.pool.Exec(context.Background(), `
+	CREATE TABLE IF NOT EXISTS saml_assertion (
+	issuer			TEXT		NOT NULL,
+	id				TEXT		NOT NULL,
+	expires_at		TIMESTAMPTZ NOT NULL,
+	created_at		TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (issuer, id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_saml_assertion_expires_at ON saml_assertion (expires_at);
+	`)
+	return err
+}
+
+func (sr *samlRepository) gc() {
+	for {
+		select {
+		case <-sr.done:
+			return
+		case <-sr.ticker.C:
+			_, err := sr.pool.Exec(context.Background(), `
+			DELETE FROM saml_assertion WHERE expires_at < $1;
+			`, time.Now())
+			// a failed cleanup is retried on the next tick
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func (sr *samlRepository) Use(ctx context.Context, issuer string, id string, expiresAt time.Time) (bool, error) {
+	// an expired row that gc has not removed yet no longer blocks the id
+	tag, err := sr.pool.Exec(ctx, `
+	INSERT INTO saml_assertion (issuer, id, expires_at) VALUES ($1, $2, $3)
+	ON CONFLICT (issuer, id) DO UPDATE SET expires_at = EXCLUDED.expires_at
+	WHERE saml_assertion.expires_at < now()
+	`, issuer, id, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,199 @@
+package saml
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"oauth/internal/models"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	bearerMethod       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	clockSkew          = time.Minute
+)
+
+type Service interface {
+	Verify(ctx context.Context, assertion string) (*models.Assertion, error)
+	AssertedClient(a *models.Assertion) (string, error)
+}
+
+type samlService struct {
+	r         Repository
+	idps      map[string]*IdentityProvider
+	audiences []string
+	recipient string
+}
+
+// NewService returns a Service that accepts bearer assertions from idps that are
+// addressed to issuer or to tokenEndpoint and delivered to tokenEndpoint. repo
+// records used assertions so none is accepted twice.
+func NewService(repo Repository, idps map[string]*IdentityProvider, issuer string, tokenEndpoint string) *samlService {
+	return &samlService{repo, idps, []string{issuer, tokenEndpoint}, tokenEndpoint}
+}
+
+type assertion struct {
+	ID      string `xml:"ID,attr"`
+	Issuer  string `xml:"Issuer"`
+	Subject struct {
+		NameID               string `xml:"NameID"`
+		SubjectConfirmations []struct {
+			Method string `xml:"Method,attr"`
+			Data   struct {
+				Recipient    string    `xml:"Recipient,attr"`
+				NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+			} `xml:"SubjectConfirmationData"`
+		} `xml:"SubjectConfirmation"`
+	} `xml:"Subject"`
+	Conditions struct {
+		NotBefore    time.Time `xml:"NotBefore,attr"`
+		NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+		Audiences    []string  `xml:"AudienceRestriction>Audience"`
+	} `xml:"Conditions"`
+}
+
+// Verify decodes a base64url encoded SAML 2.0 assertion (RFC 7522), checks its
+// XML signature against the issuing identity provider and validates its
+// audience, recipient and validity window. An assertion is accepted only once
+// (RFC 7522 section 3).
+func (ss *samlService) Verify(ctx context.Context, encoded string) (*models.Assertion, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion encoding: %s", err)
+	}
+
+	doc := etree.NewDocument()
+	err = doc.ReadFromBytes(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion: %s", err)
+	}
+	root := doc.Root()
+	if root == nil || root.Tag != "Assertion" || root.NamespaceURI() != assertionNamespace {
+		return nil, fmt.Errorf("invalid assertion: not a SAML 2.0 assertion")
+	}
+
+	// the issuer is only read here to pick the certificates, everything else is
+	// taken from the element returned by signature validation
+	issuer := root.FindElement("./Issuer")
+	if issuer == nil {
+		return nil, fmt.Errorf("invalid assertion: missing issuer")
+	}
+	idp, ok := ss.idps[strings.TrimSpace(issuer.Text())]
+	if !ok {
+		return nil, fmt.Errorf("unknown identity provider: %s", issuer.Text())
+	}
+
+	validated, err := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: idp.Certificates}).Validate(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion signature: %s", err)
+	}
+
+	signed := etree.NewDocument()
+	signed.SetRoot(validated)
+	b, err := signed.WriteToBytes()
+	if err != nil {
+		return nil, err
+	}
+	var a assertion
+	err = xml.Unmarshal(b, &a)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion: %s", err)
+	}
+
+	verified, err := ss.validate(&a, idp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the id is kept as long as the assertion could otherwise still be accepted
+	first, err := ss.r.Use(ctx, verified.Issuer, verified.ID, verified.ExpiresAt.Add(clockSkew))
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return nil, fmt.Errorf("invalid assertion: %s has already been used", verified.ID)
+	}
+
+	return verified, nil
+}
+
+func (ss *samlService) validate(a *assertion, idp *IdentityProvider) (*models.Assertion, error) {
+	now := time.Now()
+
+	if strings.TrimSpace(a.Issuer) != idp.EntityID {
+		return nil, fmt.Errorf("invalid assertion: issuer mismatch")
+	}
+
+	if a.ID == "" {
+		return nil, fmt.Errorf("invalid assertion: missing id")
+	}
+
+	subject := strings.TrimSpace(a.Subject.NameID)
+	if subject == "" {
+		return nil, fmt.Errorf("invalid assertion: missing subject")
+	}
+
+	if !a.Conditions.NotBefore.IsZero() && now.Add(clockSkew).Before(a.Conditions.NotBefore) {
+		return nil, fmt.Errorf("invalid assertion: not yet valid")
+	}
+	if !a.Conditions.NotOnOrAfter.IsZero() && !now.Add(-clockSkew).Before(a.Conditions.NotOnOrAfter) {
+		return nil, fmt.Errorf("invalid assertion: expired")
+	}
+
+	if !ss.hasAudience(a.Conditions.Audiences) {
+		return nil, fmt.Errorf("invalid assertion: unexpected audience")
+	}
+
+	var expiresAt time.Time
+	for _, sc := range a.Subject.SubjectConfirmations {
+		if sc.Method != bearerMethod || sc.Data.Recipient != ss.recipient || sc.Data.NotOnOrAfter.IsZero() {
+			continue
+		}
+		if now.Add(-clockSkew).Before(sc.Data.NotOnOrAfter) {
+			expiresAt = sc.Data.NotOnOrAfter
+			break
+		}
+	}
+	if expiresAt.IsZero() {
+		return nil, fmt.Errorf("invalid assertion: no valid bearer subject confirmation")
+	}
+
+	return &models.Assertion{
+		ID:        a.ID,
+		Issuer:    idp.EntityID,
+		Subject:   subject,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// AssertedClient returns the client a verified assertion names as its subject
+// when it is presented without client authentication. The issuing identity
+// provider must list the client, so it cannot obtain tokens for any client.
+func (ss *samlService) AssertedClient(a *models.Assertion) (string, error) {
+	idp, ok := ss.idps[a.Issuer]
+	if !ok {
+		return "", fmt.Errorf("unknown identity provider: %s", a.Issuer)
+	}
+	if !idp.Clients[a.Subject] {
+		return "", fmt.Errorf("identity provider %s may not assert client %s", a.Issuer, a.Subject)
+	}
+
+	return a.Subject, nil
+}
+
+func (ss *samlService) hasAudience(audiences []string) bool {
+	for _, a := range audiences {
+		for _, want := range ss.audiences {
+			if strings.TrimSpace(a) == want {
+				return true
+			}
+		}
+	}
+	return false
+}
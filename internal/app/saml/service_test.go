@@ -0,0 +1,273 @@
+package saml
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	testIdP      = "https://idp.example.com"
+	testIssuer   = "https://auth.example.com"
+	testEndpoint = "https://auth.example.com/v1/token"
+)
+
+// memoryRepository records used assertions in place of Postgres
+type memoryRepository struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{used: make(map[string]time.Time)}
+}
+
+func (mr *memoryRepository) Use(ctx context.Context, issuer string, id string, expiresAt time.Time) (bool, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	key := issuer + " " + id
+	if exp, ok := mr.used[key]; ok && time.Now().Before(exp) {
+		return false, nil
+	}
+	mr.used[key] = expiresAt
+	return true, nil
+}
+
+type testAssertion struct {
+	id        string
+	issuer    string
+	subject   string
+	audience  string
+	recipient string
+	expiresAt time.Time
+}
+
+func validAssertion() testAssertion {
+	return testAssertion{
+		id:        "_a1",
+		issuer:    testIdP,
+		subject:   "user@example.com",
+		audience:  testEndpoint,
+		recipient: testEndpoint,
+		expiresAt: time.Now().Add(5 * time.Minute),
+	}
+}
+
+func newTestIdP(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %s", err)
+	}
+
+	return key, cert
+}
+
+func (ta testAssertion) xml() string {
+	now := time.Now().UTC()
+	return fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="%s" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID>%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">`+
+		`<saml:SubjectConfirmationData Recipient="%s" NotOnOrAfter="%s"/>`+
+		`</saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s">`+
+		`<saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction>`+
+		`</saml:Conditions></saml:Assertion>`,
+		ta.id, now.Format(time.RFC3339), ta.issuer, ta.subject, ta.recipient, ta.expiresAt.UTC().Format(time.RFC3339),
+		now.Add(-time.Minute).Format(time.RFC3339), ta.expiresAt.UTC().Format(time.RFC3339), ta.audience)
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, cert *x509.Certificate, xml string) string {
+	doc := etree.NewDocument()
+	err := doc.ReadFromString(xml)
+	if err != nil {
+		t.Fatalf("Failed to parse assertion: %s", err)
+	}
+
+	ctx, err := dsig.NewSigningContext(key, [][]byte{cert.Raw})
+	if err != nil {
+		t.Fatalf("Failed to create signing context: %s", err)
+	}
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	signed, err := ctx.SignEnveloped(doc.Root())
+	if err != nil {
+		t.Fatalf("Failed to sign assertion: %s", err)
+	}
+
+	out := etree.NewDocument()
+	out.SetRoot(signed)
+	s, err := out.WriteToString()
+	if err != nil {
+		t.Fatalf("Failed to serialize assertion: %s", err)
+	}
+	return s
+}
+
+func encode(xml string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(xml))
+}
+
+func TestVerify(t *testing.T) {
+	key, cert := newTestIdP(t)
+	service := NewService(newMemoryRepository(), map[string]*IdentityProvider{
+		testIdP: {EntityID: testIdP, Certificates: []*x509.Certificate{cert}},
+	}, testIssuer, testEndpoint)
+
+	a, err := service.Verify(context.Background(), encode(sign(t, key, cert, validAssertion().xml())))
+	if err != nil {
+		t.Fatalf("Failed to verify assertion: %s", err)
+	}
+
+	if a.Subject != "user@example.com" || a.Issuer != testIdP {
+		t.Fatalf("Unexpected assertion: %+v", a)
+	}
+	// an assertion is single use, another one from the same idp is accepted
+	_, err = service.Verify(context.Background(), encode(sign(t, key, cert, validAssertion().xml())))
+	if err == nil {
+		t.Fatal("Expected replayed assertion to be rejected, got nil")
+	}
+	next := validAssertion()
+	next.id = "_a2"
+	_, err = service.Verify(context.Background(), encode(sign(t, key, cert, next.xml())))
+	if err != nil {
+		t.Fatalf("Failed to verify a new assertion: %s", err)
+	}
+}
+
+func TestVerifyRejected(t *testing.T) {
+	key, cert := newTestIdP(t)
+	otherKey, otherCert := newTestIdP(t)
+	service := NewService(newMemoryRepository(), map[string]*IdentityProvider{
+		testIdP: {EntityID: testIdP, Certificates: []*x509.Certificate{cert}},
+	}, testIssuer, testEndpoint)
+
+	wrongAudience := validAssertion()
+	wrongAudience.audience = "https://other.example.com"
+	wrongRecipient := validAssertion()
+	wrongRecipient.recipient = "https://other.example.com/token"
+	expired := validAssertion()
+	expired.expiresAt = time.Now().Add(-5 * time.Minute)
+	unknownIssuer := validAssertion()
+	unknownIssuer.issuer = "https://unknown.example.com"
+	missingID := validAssertion()
+	missingID.id = ""
+
+	tampered := sign(t, key, cert, validAssertion().xml())
+	tampered = strings.Replace(tampered, "user@example.com", "admin@example.com", 1)
+
+	tests := []struct {
+		name      string
+		assertion string
+	}{
+		{"unsigned", encode(validAssertion().xml())},
+		{"tampered", encode(tampered)},
+		{"untrusted signer", encode(sign(t, otherKey, otherCert, validAssertion().xml()))},
+		{"wrong audience", encode(sign(t, key, cert, wrongAudience.xml()))},
+		{"wrong recipient", encode(sign(t, key, cert, wrongRecipient.xml()))},
+		{"expired", encode(sign(t, key, cert, expired.xml()))},
+		{"unknown issuer", encode(sign(t, key, cert, unknownIssuer.xml()))},
+		{"missing id", encode(sign(t, key, cert, missingID.xml()))},
+		{"not base64url", "<saml:Assertion/>"},
+	}
+
+	for _, tt := range tests {
+		_, err := service.Verify(context.Background(), tt.assertion)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestLoadMetadata(t *testing.T) {
+	_, cert := newTestIdP(t)
+	dir := t.TempDir()
+	metadata := fmt.Sprintf(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">`+
+		`<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">`+
+		`<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">`+
+		`<ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data>`+
+		`</ds:KeyInfo></md:KeyDescriptor></md:IDPSSODescriptor></md:EntityDescriptor>`,
+		testIdP, base64.StdEncoding.EncodeToString(cert.Raw))
+	err := os.WriteFile(filepath.Join(dir, "idp.xml"), []byte(metadata), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write metadata: %s", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "idp.clients.json"), []byte(`["partner-client"]`), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write clients: %s", err)
+	}
+
+	idps, err := LoadMetadata(dir)
+	if err != nil {
+		t.Fatalf("Failed to load metadata: %s", err)
+	}
+
+	idp, ok := idps[testIdP]
+	if !ok || len(idp.Certificates) != 1 || !idp.Certificates[0].Equal(cert) {
+		t.Fatalf("Unexpected identity providers: %v", idps)
+	}
+	if len(idp.Clients) != 1 || !idp.Clients["partner-client"] {
+		t.Fatalf("Unexpected clients: %v", idp.Clients)
+	}
+}
+
+func TestAssertedClient(t *testing.T) {
+	key, cert := newTestIdP(t)
+	service := NewService(newMemoryRepository(), map[string]*IdentityProvider{
+		testIdP: {EntityID: testIdP, Certificates: []*x509.Certificate{cert}, Clients: map[string]bool{"partner-client": true}},
+	}, testIssuer, testEndpoint)
+
+	allowed := validAssertion()
+	allowed.subject = "partner-client"
+	a, err := service.Verify(context.Background(), encode(sign(t, key, cert, allowed.xml())))
+	if err != nil {
+		t.Fatalf("Failed to verify assertion: %s", err)
+	}
+	id, err := service.AssertedClient(a)
+	if err != nil || id != "partner-client" {
+		t.Fatalf("Expected partner-client, got %q: %v", id, err)
+	}
+
+	// a trusted identity provider cannot name a client outside its list
+	other := validAssertion()
+	other.id = "_a2"
+	other.subject = "other-client"
+	a, err = service.Verify(context.Background(), encode(sign(t, key, cert, other.xml())))
+	if err != nil {
+		t.Fatalf("Failed to verify assertion: %s", err)
+	}
+	_, err = service.AssertedClient(a)
+	if err == nil {
+		t.Fatal("Expected client outside the allow-list to be rejected, got nil")
+	}
+}
@@ -0,0 +1,50 @@
+package subject
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"oauth/internal/models"
+)
+
+type Service interface {
+	Subject(client *models.Client, userID string) string
+	Salted() bool
+}
+
+type subjectService struct {
+	salt []byte
+}
+
+// NewService returns a Service that salts pairwise identifiers with salt
+func NewService(salt string) *subjectService {
+	return &subjectService{[]byte(salt)}
+}
+
+// Salted reports whether a salt is configured. Pairwise identifiers are only
+// issued with one, since unsalted ones can be recomputed by anyone.
+func (ss *subjectService) Salted() bool {
+	return len(ss.salt) > 0
+}
+
+// Subject returns the sub claim client sees for userID. Public clients get the
+// user ID itself. Pairwise clients get a salted hash of their sector
+// identifier and the user ID, so clients in different sectors cannot
+// correlate users.
+func (ss *subjectService) Subject(client *models.Client, userID string) string {
+	if client.SubjectType != models.SubjectTypePairwise {
+		return userID
+	}
+
+	sector := client.SectorIdentifierURI
+	u, err := url.Parse(client.SectorIdentifierURI)
+	if err == nil && u.Host != "" {
+		sector = u.Host
+	}
+
+	h := sha256.New()
+	h.Write([]byte(sector))
+	h.Write([]byte(userID))
+	h.Write(ss.salt)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package subject
+
+import (
+	"oauth/internal/models"
+	"testing"
+)
+
+func TestPublicSubject(t *testing.T) {
+	service := NewService("salt")
+	client := &models.Client{ID: "client", SubjectType: models.SubjectTypePublic}
+
+	if sub := service.Subject(client, "user"); sub != "user" {
+		t.Fatalf("Expected public subject to be the user ID, got %s", sub)
+	}
+}
+
+func TestPairwiseSubject(t *testing.T) {
+	service := NewService("salt")
+	pairwise := func(id string, sector string) *models.Client {
+		return &models.Client{ID: id, SubjectType: models.SubjectTypePairwise, SectorIdentifierURI: sector}
+	}
+
+	a := service.Subject(pairwise("a", "https://one.example.com/sector.json"), "user")
+	b := service.Subject(pairwise("b", "https://one.example.com/other.json"), "user")
+	c := service.Subject(pairwise("c", "https://two.example.com/sector.json"), "user")
+
+	if a == "user" {
+		t.Fatal("Expected pairwise subject to differ from the user ID")
+	}
+	if a != b {
+		t.Fatalf("Expected clients in the same sector to share a subject, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Fatal("Expected clients in different sectors to get different subjects")
+	}
+	if a == service.Subject(pairwise("a", "https://one.example.com/sector.json"), "other") {
+		t.Fatal("Expected different users to get different subjects")
+	}
+	if a == NewService("pepper").Subject(pairwise("a", "https://one.example.com/sector.json"), "user") {
+		t.Fatal("Expected the salt to change the subject")
+	}
+}
+
+func TestSalted(t *testing.T) {
+	if NewService("").Salted() {
+		t.Fatal("Expected an empty salt to be reported as unsalted")
+	}
+	if !NewService("salt").Salted() {
+		t.Fatal("Expected a salt to be reported as salted")
+	}
+}
@@ -2,39 +2,88 @@ package token
 
 import (
 	"context"
-	"crypto/rsa"
+	"oauth/internal/app/resource"
 	"oauth/internal/models"
+	pkgjwt "oauth/pkg/jwt"
+	"oauth/pkg/keyring"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+// AccessTokenTTL is how long issued access tokens stay valid
+const AccessTokenTTL = 10 * time.Minute
+
 type Service interface {
-	Create(ctx context.Context, client *models.Client) (*models.Token, error)
+	Create(ctx context.Context, client *models.Client, auth *models.Authentication, resource string) (*models.Token, error)
 	GetAccess(ctx context.Context, token string) (*models.Token, error)
-	Public() *rsa.PublicKey
+	Keys() *keyring.Keyring
 }
 
 type tokenService struct {
-	r Repository
-	k *rsa.PrivateKey
+	r         Repository
+	k         *keyring.Keyring
+	resources resource.Service
+}
+
+// claims are the claims of an access token
+type claims struct {
+	jwt.StandardClaims
+	ACR      string   `json:"acr,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	AuthTime int64    `json:"auth_time,omitempty"`
 }
 
-func NewService(repo Repository, key *rsa.PrivateKey) *tokenService {
-	return &tokenService{repo, key}
+func NewService(repo Repository, keys *keyring.Keyring, resources resource.Service) *tokenService {
+	return &tokenService{repo, keys, resources}
 }
 
-func (ts *tokenService) Create(ctx context.Context, client *models.Client) (*models.Token, error) {
-	exp := time.Now().Add(10 * time.Minute)
-	claims := jwt.StandardClaims{
-		Audience:  client.ID,
-		ExpiresAt: exp.Unix(),
+// Create issues an access token to client. auth describes the user the token
+// acts for and is nil for client credentials. audience is the registered
+// resource server the token is for (RFC 8707) and the token is encrypted to
+// its key when it has one. Without an audience the client is the audience.
+func (ts *tokenService) Create(ctx context.Context, client *models.Client, auth *models.Authentication, audience string) (*models.Token, error) {
+	var rs *resource.Server
+	if audience != "" {
+		var err error
+		rs, err = ts.resources.Lookup(audience)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		audience = client.ID
+	}
+
+	exp := time.Now().Add(AccessTokenTTL)
+	claims := claims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  audience,
+			ExpiresAt: exp.Unix(),
+		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	access, err := token.SignedString(ts.k)
+	if auth != nil {
+		claims.Subject = auth.Subject
+		claims.ACR = auth.ACR
+		claims.AMR = auth.AMR
+		if !auth.AuthTime.IsZero() {
+			claims.AuthTime = auth.AuthTime.Unix()
+		}
+	}
+	key, err := ts.k.Active()
 	if err != nil {
 		return nil, err
 	}
+	access, err := pkgjwt.Sign(claims, key.Alg, key.ID, key.Signer)
+	if err != nil {
+		return nil, err
+	}
+	// only the resource server holding the registered key can read the claims
+	if rs != nil && rs.EncryptionKey != nil {
+		access, err = pkgjwt.Encrypt(access, rs.EncryptionAlg, rs.EncryptionEnc, rs.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	t := &models.Token{Access: access, ExpiresAt: exp}
 	err = ts.r.Create(ctx, t)
@@ -49,6 +98,6 @@ func (ts *tokenService) GetAccess(ctx context.Context, token string) (*models.To
 	return ts.r.GetByToken(ctx, token)
 }
 
-func (ts *tokenService) Public() *rsa.PublicKey {
-	return &ts.k.PublicKey
+func (ts *tokenService) Keys() *keyring.Keyring {
+	return ts.k
 }
@@ -3,7 +3,19 @@ package errors
 import "errors"
 
 var (
-	ErrUnsupportedGrantType = errors.New("unsupported grant_type")
-	ErrInvalidClient        = errors.New("invalid client")
-	ErrInternalServer       = errors.New("internal server issue")
+	ErrUnsupportedGrantType  = errors.New("unsupported grant_type")
+	ErrInvalidClient         = errors.New("invalid client")
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+	ErrUnauthorizedClient    = errors.New("unauthorized client")
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrInvalidGrant          = errors.New("invalid grant")
+	ErrInvalidRequestObject  = errors.New("invalid request object")
+	ErrInvalidRequestURI     = errors.New("invalid request_uri")
+	ErrAuthorizationPending  = errors.New("authorization pending")
+	ErrSlowDown              = errors.New("slow down")
+	ErrExpiredToken          = errors.New("expired token")
+	ErrAccessDenied          = errors.New("access denied")
+	ErrInsufficientAuth      = errors.New("insufficient authentication level")
+	ErrInvalidTarget         = errors.New("invalid target")
+	ErrInternalServer        = errors.New("internal server issue")
 )
@@ -2,12 +2,67 @@ package models
 
 import "time"
 
+// Backchannel token delivery modes supported for CIBA clients
+const (
+	DeliveryModePoll = "poll"
+	DeliveryModePing = "ping"
+)
+
+// Subject identifier types (OpenID Connect Core section 8)
+const (
+	SubjectTypePublic   = "public"
+	SubjectTypePairwise = "pairwise"
+)
+
 type Client struct {
-	ID     string `json:"id"`
-	Secret string `json:"secret"`
+	ID                                    string   `json:"id"`
+	Secret                                string   `json:"secret"`
+	BackchannelTokenDeliveryMode          string   `json:"backchannel_token_delivery_mode,omitempty"`
+	BackchannelClientNotificationEndpoint string   `json:"backchannel_client_notification_endpoint,omitempty"`
+	JWKS                                  string   `json:"jwks,omitempty"`
+	RequestURIs                           []string `json:"request_uris,omitempty"`
+	RequireSignedRequestObject            bool     `json:"require_signed_request_object,omitempty"`
+	SubjectType                           string   `json:"subject_type,omitempty"`
+	SectorIdentifierURI                   string   `json:"sector_identifier_uri,omitempty"`
 }
 
 type Token struct {
 	Access    string    `json:"access_token"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
+
+// BackchannelAuthRequest is a CIBA authentication request awaiting a decision
+// from the user's authentication device
+type BackchannelAuthRequest struct {
+	ID                      string    `json:"auth_req_id"`
+	ClientID                string    `json:"client_id"`
+	Scope                   string    `json:"scope"`
+	LoginHint               string    `json:"login_hint"`
+	BindingMessage          string    `json:"binding_message,omitempty"`
+	ACRValues               string    `json:"acr_values,omitempty"`
+	ClientNotificationToken string    `json:"-"`
+	CallbackToken           string    `json:"-"`
+	Status                  string    `json:"status"`
+	ACR                     string    `json:"acr,omitempty"`
+	AMR                     []string  `json:"amr,omitempty"`
+	AuthTime                time.Time `json:"auth_time"`
+	Interval                int       `json:"interval"`
+	ExpiresAt               time.Time `json:"expires_at"`
+	LastPolledAt            time.Time `json:"-"`
+}
+
+// Authentication describes how the user a token acts for was authenticated
+type Authentication struct {
+	Subject  string    `json:"sub"`
+	ACR      string    `json:"acr,omitempty"`
+	AMR      []string  `json:"amr,omitempty"`
+	AuthTime time.Time `json:"auth_time"`
+}
+
+// Assertion is a verified SAML 2.0 bearer assertion
+type Assertion struct {
+	ID        string    `json:"id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -2,17 +2,86 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	oauth "oauth/api"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func (a *app) GetKey(ctx context.Context, req *oauth.KeyRequest) (*oauth.KeyResponse, error) {
-	key, err := a.m.GetPublicKey()
+	active, err := a.m.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	resp := &oauth.KeyResponse{}
+	resp.Active, err = publicKey(active, req.Format)
 	if err != nil {
 		return nil, err
 	}
-	return &oauth.KeyResponse{Key: key}, nil
+	resp.Key = resp.Active.Key
+
+	for _, k := range a.m.GetPublicKeys() {
+		pk, err := publicKey(k, req.Format)
+		if err != nil {
+			return nil, err
+		}
+		resp.Keys = append(resp.Keys, pk)
+	}
+
+	if req.Format == oauth.KeyFormat_JWKS {
+		jwks, err := a.m.GetJWKS()
+		if err != nil {
+			return nil, err
+		}
+		resp.Key, err = json.Marshal(jwks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
 }
 
 func (a *app) ValidateToken(ctx context.Context, token *oauth.TokenRequest) (*oauth.TokenResponse, error) {
 	return &oauth.TokenResponse{Valid: a.m.ValidateToken(ctx, token.Token)}, nil
 }
+
+// publicKey encodes k in format along with its kid, algorithm, thumbprint and
+// validity window. Keys in a JWKS are encoded as individual JWKs.
+func publicKey(k *keyring.Key, format oauth.KeyFormat) (*oauth.PublicKey, error) {
+	jwk, err := k.JWK()
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	switch format {
+	case oauth.KeyFormat_PEM:
+		b, err = key.PublicBytes(k.Public())
+	case oauth.KeyFormat_DER:
+		b, err = key.PublicDER(k.Public())
+	case oauth.KeyFormat_JWK, oauth.KeyFormat_JWKS:
+		b, err = json.Marshal(jwk)
+	default:
+		return nil, fmt.Errorf("unsupported key format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pk := &oauth.PublicKey{Kid: k.ID, Key: b, Alg: k.Alg, Thumbprint: thumbprint}
+	if !k.NotBefore.IsZero() {
+		pk.NotBefore = timestamppb.New(k.NotBefore)
+	}
+	if !k.NotAfter.IsZero() {
+		pk.NotAfter = timestamppb.New(k.NotAfter)
+	}
+	return pk, nil
+}
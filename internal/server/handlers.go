@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"oauth/internal/errors"
 	"oauth/internal/models"
@@ -9,6 +10,11 @@ import (
 	"time"
 )
 
+const (
+	grantTypeCIBA = "urn:openid:params:grant-type:ciba"
+	grantTypeSAML = "urn:ietf:params:oauth:grant-type:saml2-bearer"
+)
+
 type response struct {
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
@@ -20,21 +26,80 @@ func writeJSON(w http.ResponseWriter, data any, code int) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// writeError writes err using the status code that matches it
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	switch err {
+	case errors.ErrInternalServer:
+		code = http.StatusInternalServerError
+	case errors.ErrInvalidClient:
+		code = http.StatusUnauthorized
+	case errors.ErrUnauthorizedClient, errors.ErrAccessDenied:
+		code = http.StatusForbidden
+	}
+
+	writeJSON(w, response{Message: err.Error()}, code)
+}
+
+type registerRequest struct {
+	BackchannelTokenDeliveryMode          string          `json:"backchannel_token_delivery_mode"`
+	BackchannelClientNotificationEndpoint string          `json:"backchannel_client_notification_endpoint"`
+	JWKS                                  json.RawMessage `json:"jwks"`
+	RequestURIs                           []string        `json:"request_uris"`
+	RequireSignedRequestObject            bool            `json:"require_signed_request_object"`
+	SubjectType                           string          `json:"subject_type"`
+	SectorIdentifierURI                   string          `json:"sector_identifier_uri"`
+}
+
 type registerResponse struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	ClientID                              string          `json:"client_id"`
+	ClientSecret                          string          `json:"client_secret"`
+	BackchannelTokenDeliveryMode          string          `json:"backchannel_token_delivery_mode,omitempty"`
+	BackchannelClientNotificationEndpoint string          `json:"backchannel_client_notification_endpoint,omitempty"`
+	JWKS                                  json.RawMessage `json:"jwks,omitempty"`
+	RequestURIs                           []string        `json:"request_uris,omitempty"`
+	RequireSignedRequestObject            bool            `json:"require_signed_request_object,omitempty"`
+	SubjectType                           string          `json:"subject_type"`
+	SectorIdentifierURI                   string          `json:"sector_identifier_uri,omitempty"`
 }
 
 func (a *app) registerHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		client, err := a.m.RegisterClient(ctx)
+
+		// client metadata is optional, an empty body registers a client_credentials client
+		var req registerRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && err != io.EOF {
+			writeJSON(w, response{Message: errors.ErrInvalidClientMetadata.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		client, err := a.m.RegisterClient(ctx, &models.Client{
+			BackchannelTokenDeliveryMode:          req.BackchannelTokenDeliveryMode,
+			BackchannelClientNotificationEndpoint: req.BackchannelClientNotificationEndpoint,
+			JWKS:                                  string(req.JWKS),
+			RequestURIs:                           req.RequestURIs,
+			RequireSignedRequestObject:            req.RequireSignedRequestObject,
+			SubjectType:                           req.SubjectType,
+			SectorIdentifierURI:                   req.SectorIdentifierURI,
+		})
 		if err != nil {
-			writeJSON(w, response{Message: err.Error()}, http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
-		writeJSON(w, registerResponse{ClientID: client.ID, ClientSecret: client.Secret}, http.StatusCreated)
+		writeJSON(w, registerResponse{
+			ClientID:                              client.ID,
+			ClientSecret:                          client.Secret,
+			BackchannelTokenDeliveryMode:          client.BackchannelTokenDeliveryMode,
+			BackchannelClientNotificationEndpoint: client.BackchannelClientNotificationEndpoint,
+			JWKS:                                  json.RawMessage(client.JWKS),
+			RequestURIs:                           client.RequestURIs,
+			RequireSignedRequestObject:            client.RequireSignedRequestObject,
+			SubjectType:                           client.SubjectType,
+			SectorIdentifierURI:                   client.SectorIdentifierURI,
+		}, http.StatusCreated)
 	}
 }
 
@@ -52,12 +117,17 @@ func (a *app) tokenHandler() http.HandlerFunc {
 			return
 		}
 
-		token, err := a.m.GenerateToken(ctx, client)
-		if err == errors.ErrInternalServer {
-			writeJSON(w, response{Message: err.Error()}, http.StatusInternalServerError)
-			return
-		} else if err == errors.ErrInvalidClient || err != nil {
-			writeJSON(w, response{Message: err.Error()}, http.StatusUnauthorized)
+		var token *models.Token
+		switch r.Form.Get("grant_type") {
+		case grantTypeCIBA:
+			token, err = a.m.GenerateBackchannelToken(ctx, client, r.Form.Get("auth_req_id"), r.Form.Get("resource"))
+		case grantTypeSAML:
+			token, err = a.m.GenerateSAMLToken(ctx, client, r.Form.Get("assertion"), r.Form.Get("resource"))
+		default:
+			token, err = a.m.GenerateToken(ctx, client, r.Form.Get("resource"))
+		}
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 
@@ -66,12 +136,25 @@ func (a *app) tokenHandler() http.HandlerFunc {
 }
 
 func (a *app) validateTokenHandlerRequest(r *http.Request) (*models.Client, error) {
-	gt := r.FormValue("grant_type")
-	if gt != "client_credentials" {
-		return nil, errors.ErrUnsupportedGrantType
+	switch r.FormValue("grant_type") {
+	case "client_credentials", grantTypeCIBA:
+		return clientFromForm(r)
+	case grantTypeSAML:
+		// client authentication is optional for assertion grants (RFC 7522 section 3)
+		if r.Form.Get("assertion") == "" {
+			return nil, errors.ErrInvalidRequest
+		}
+		if r.Form.Get("client_id") == "" {
+			return nil, nil
+		}
+		return clientFromForm(r)
 	}
 
-	clientID := r.Form.Get("client_id")
+	return nil, errors.ErrUnsupportedGrantType
+}
+
+func clientFromForm(r *http.Request) (*models.Client, error) {
+	clientID := r.FormValue("client_id")
 	if clientID == "" {
 		return nil, errors.ErrInvalidClient
 	}
@@ -80,6 +163,73 @@ func (a *app) validateTokenHandlerRequest(r *http.Request) (*models.Client, erro
 	return &models.Client{ID: clientID, Secret: clientSecret}, nil
 }
 
+type backchannelAuthResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int    `json:"expires_in"`
+	Interval  int    `json:"interval,omitempty"`
+}
+
+func (a *app) backchannelAuthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		client, err := clientFromForm(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		authReq, err := a.m.BackchannelAuthorize(ctx, client, r.Form)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, backchannelAuthResponse{
+			AuthReqID: authReq.ID,
+			ExpiresIn: int(time.Until(authReq.ExpiresAt).Seconds()),
+			Interval:  authReq.Interval,
+		}, http.StatusOK)
+	}
+}
+
+// backchannelCallbackHandler receives the user's decision from the authentication device
+func (a *app) backchannelCallbackHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var approved bool
+		switch r.FormValue("decision") {
+		case "approve":
+			approved = true
+		case "deny":
+		default:
+			writeError(w, errors.ErrInvalidRequest)
+			return
+		}
+
+		err := a.m.ResolveBackchannelAuth(ctx, r.Form.Get("auth_req_id"), r.Form.Get("callback_token"), approved,
+			r.Form.Get("acr"), strings.Fields(r.Form.Get("amr")))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (a *app) jwksHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwks, err := a.m.GetJWKS()
+		if err != nil {
+			writeJSON(w, response{Message: errors.ErrInternalServer.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, jwks, http.StatusOK)
+	}
+}
+
 func (a *app) tokenValidationHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, a.validateBearerToken(r), http.StatusOK)
@@ -0,0 +1,375 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"oauth/config"
+	"oauth/internal/app/rotation"
+	"oauth/internal/app/token"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+	"oauth/pkg/signer"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const keysUsage = `usage: oauth keys <command> [flags] [args]
+
+commands:
+  generate [-alg RS256] [-bits 2048] [-activate]   generate a new signing key
+  import [-alg alg] [-activate] <key.pem>          add an existing PEM private key
+  list                                             list every stored key
+  activate <kid>                                   make a key the active signing key
+  retire [-after duration] <kid>                   retire a key, it verifies tokens until -after has passed
+  export-public [-format pem|der|jwk|jwks] [kid]   print a public key, the active one by default`
+
+// keyCommand edits the keys in the store the server is configured with
+type keyCommand struct {
+	cfg   *config.Config
+	store keyring.Store
+	// locker is nil when lockKeys is false
+	locker rotation.Locker
+	out    io.Writer
+	now    time.Time
+}
+
+// Keys runs a key management command against the key store the server is
+// configured with, so changes are picked up by running servers
+func Keys(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	passphrase, err := key.ReadPassphrase(cfg.KeyPassphrase, cfg.KeyPassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	kc := &keyCommand{cfg: cfg, out: out, now: time.Now()}
+	var dbpool *pgxpool.Pool
+	if lockKeys(cfg) {
+		dbpool, err = pgxpool.Connect(context.Background(), cfg.DSN)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %s", err)
+		}
+		defer dbpool.Close()
+		kc.locker = rotation.NewAdvisoryLocker(dbpool)
+	}
+
+	kc.store, err = keyStore(cfg, dbpool, passphrase)
+	if err != nil {
+		return fmt.Errorf("unable to setup key store: %s", err)
+	}
+	if kc.store == nil {
+		return fmt.Errorf("key commands require KEYRING_PATH or the postgres signer")
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "generate":
+		return kc.generate(ctx, args[1:])
+	case "import":
+		return kc.importKey(ctx, args[1:], passphrase)
+	case "list":
+		return kc.list(ctx)
+	case "activate":
+		return kc.activate(ctx, args[1:])
+	case "retire":
+		return kc.retire(ctx, args[1:])
+	case "export-public":
+		return kc.exportPublic(ctx, args[1:])
+	}
+
+	return fmt.Errorf("unknown command: %s\n\n%s", args[0], keysUsage)
+}
+
+// lockKeys reports whether key edits take the rotation advisory lock. Servers
+// hold it while rotating with KEY_ROTATION_INTERVAL set, and keys stored in
+// Postgres are shared by every replica.
+func lockKeys(cfg *config.Config) bool {
+	return cfg.Signer == "postgres" || cfg.KeyRotationInterval > 0
+}
+
+func (kc *keyCommand) generate(ctx context.Context, args []string) error {
+	alg := kc.cfg.SigningAlg
+	if alg == "" {
+		alg = "RS256"
+	}
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.StringVar(&alg, "alg", alg, "signing algorithm")
+	bits := fs.Int("bits", 0, "RSA key size, 2048 when unset")
+	activate := fs.Bool("activate", false, "make the new key the active signing key")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	private, err := key.GenerateSize(alg, *bits)
+	if err != nil {
+		return err
+	}
+	k, err := keyring.NewKey(private, alg, keyring.StatePending)
+	if err != nil {
+		return err
+	}
+
+	return kc.add(ctx, k, *activate)
+}
+
+func (kc *keyCommand) importKey(ctx context.Context, args []string, passphrase []byte) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	alg := fs.String("alg", "", "signing algorithm, derived from the key when unset")
+	activate := fs.Bool("activate", false, "make the imported key the active signing key")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: oauth keys import [-alg alg] [-activate] <key.pem>")
+	}
+
+	s, err := signer.NewFileSigner(fs.Arg(0), passphrase)
+	if err != nil {
+		return err
+	}
+	k, err := keyring.NewKey(s, *alg, keyring.StatePending)
+	if err != nil {
+		return err
+	}
+
+	return kc.add(ctx, k, *activate)
+}
+
+// add stores k as a pending key, or as the active key when activate is set
+// or the store holds no keys yet, and prints its kid
+func (kc *keyCommand) add(ctx context.Context, k *keyring.Key, activate bool) error {
+	k.CreatedAt = kc.now
+	err := kc.update(ctx, func(keys []*keyring.Key) ([]*keyring.Key, error) {
+		for _, existing := range keys {
+			if existing.ID == k.ID {
+				return nil, fmt.Errorf("key %s is already stored", k.ID)
+			}
+		}
+		keys = append(keys, k)
+		if activate || len(keys) == 1 {
+			return kc.promote(keys, k.ID)
+		}
+		return keys, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(kc.out, k.ID)
+	return nil
+}
+
+func (kc *keyCommand) list(ctx context.Context) error {
+	keys, err := kc.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(kc.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATE\tCREATED\tACTIVATED\tNOT BEFORE\tNOT AFTER")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Alg, k.State,
+			formatTime(k.CreatedAt), formatTime(k.ActivatedAt), formatTime(k.NotBefore), formatTime(k.NotAfter))
+	}
+	return w.Flush()
+}
+
+func (kc *keyCommand) activate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: oauth keys activate <kid>")
+	}
+
+	return kc.update(ctx, func(keys []*keyring.Key) ([]*keyring.Key, error) {
+		return kc.promote(keys, args[0])
+	})
+}
+
+func (kc *keyCommand) retire(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("retire", flag.ContinueOnError)
+	after := fs.Duration("after", token.AccessTokenTTL+kc.cfg.KeyRotationGrace, "how long the key keeps verifying tokens")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: oauth keys retire [-after duration] <kid>")
+	}
+	kid := fs.Arg(0)
+
+	return kc.update(ctx, func(keys []*keyring.Key) ([]*keyring.Key, error) {
+		k, err := find(keys, kid)
+		if err != nil {
+			return nil, err
+		}
+		if k.State == keyring.StateActive {
+			return nil, fmt.Errorf("key %s is active, activate another key first", kid)
+		}
+		k.State = keyring.StateRetired
+		k.NotAfter = kc.now.Add(*after)
+		return keys, nil
+	})
+}
+
+func (kc *keyCommand) exportPublic(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export-public", flag.ContinueOnError)
+	format := fs.String("format", "pem", "pem, der, jwk or jwks")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (*format == "jwks" && fs.NArg() != 0) {
+		return fmt.Errorf("usage: oauth keys export-public [-format pem|der|jwk] [kid] or -format jwks")
+	}
+
+	keys, err := kc.load(ctx)
+	if err != nil {
+		return err
+	}
+	ring, err := keyring.New(keys)
+	if err != nil {
+		return err
+	}
+
+	var k *keyring.Key
+	if fs.NArg() == 1 {
+		k, err = find(keys, fs.Arg(0))
+	} else {
+		k, err = ring.Active()
+	}
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	switch *format {
+	case "pem":
+		b, err = key.PublicBytes(k.Public())
+	case "der":
+		b, err = key.PublicDER(k.Public())
+	case "jwk":
+		b, err = marshalKey(k.JWK())
+	case "jwks":
+		b, err = marshalKey(ring.JWKS())
+	default:
+		return fmt.Errorf("unsupported key format: %s", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = kc.out.Write(b)
+	return err
+}
+
+// promote makes kid the active key. The previous active key is retired and
+// keeps verifying the tokens it signed for their lifetime plus the grace period.
+func (kc *keyCommand) promote(keys []*keyring.Key, kid string) ([]*keyring.Key, error) {
+	k, err := find(keys, kid)
+	if err != nil {
+		return nil, err
+	}
+	if k.State == keyring.StateActive {
+		return nil, fmt.Errorf("key %s is already active", kid)
+	}
+
+	for _, old := range keys {
+		if old.State == keyring.StateActive {
+			old.State = keyring.StateRetired
+			old.NotAfter = kc.now.Add(token.AccessTokenTTL + kc.cfg.KeyRotationGrace)
+		}
+	}
+	k.State = keyring.StateActive
+	k.ActivatedAt = kc.now
+	k.NotAfter = time.Time{}
+	return keys, nil
+}
+
+// load returns the stored keys. A keyring manifest that does not exist yet
+// holds no keys.
+func (kc *keyCommand) load(ctx context.Context) ([]*keyring.Key, error) {
+	if kc.cfg.Signer == "file" {
+		_, err := os.Stat(kc.cfg.KeyringPath)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	return kc.store.Load(ctx)
+}
+
+// update applies fn to copies of the stored keys and saves the result once it
+// forms a valid keyring. With a database it holds the rotation lock so it
+// cannot race a server rotating keys.
+func (kc *keyCommand) update(ctx context.Context, fn func(keys []*keyring.Key) ([]*keyring.Key, error)) error {
+	apply := func(ctx context.Context) error {
+		stored, err := kc.load(ctx)
+		if err != nil {
+			return err
+		}
+		keys := make([]*keyring.Key, 0, len(stored)+1)
+		for _, k := range stored {
+			k := *k
+			keys = append(keys, &k)
+		}
+
+		keys, err = fn(keys)
+		if err != nil {
+			return err
+		}
+		_, err = keyring.New(keys)
+		if err != nil {
+			return err
+		}
+		return kc.store.Save(ctx, keys)
+	}
+
+	if kc.locker == nil {
+		return apply(ctx)
+	}
+	locked, err := kc.locker.TryLock(ctx, apply)
+	if err == nil && !locked {
+		return fmt.Errorf("keys are being rotated, try again")
+	}
+	return err
+}
+
+// marshalKey indents v as JSON for printing
+func marshalKey(v any, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func find(keys []*keyring.Key, kid string) (*keyring.Key, error) {
+	for _, k := range keys {
+		if k.ID == kid {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown kid: %s", kid)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
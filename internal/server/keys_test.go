@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"oauth/config"
+	"oauth/pkg/jwt"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runKeys runs a key command and returns what it printed
+func runKeys(t *testing.T, cfg *config.Config, args ...string) string {
+	var out bytes.Buffer
+	err := Keys(cfg, args, &out)
+	if err != nil {
+		t.Fatalf("keys %s: %s", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Signer: "file", KeyringPath: filepath.Join(dir, "keyring.json"), KeyRotationGrace: time.Hour}
+
+	// the first key of an empty keyring becomes active
+	first := strings.TrimSpace(runKeys(t, cfg, "generate", "-bits", "3072"))
+	second := strings.TrimSpace(runKeys(t, cfg, "generate", "-alg", "ES256"))
+
+	private, err := key.Generate("EdDSA")
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	b, err := key.PrivateBytes(private, nil)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+	importPath := filepath.Join(dir, "import.pem")
+	os.WriteFile(importPath, b, 0600)
+	third := strings.TrimSpace(runKeys(t, cfg, "import", importPath))
+
+	keys, err := keyring.LoadFile(cfg.KeyringPath, nil)
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %s", err)
+	}
+	states := map[string]string{}
+	for _, k := range keys {
+		states[k.ID] = k.State
+	}
+	if states[first] != keyring.StateActive || states[second] != keyring.StatePending || states[third] != keyring.StatePending {
+		t.Fatalf("Unexpected key states: %v", states)
+	}
+
+	runKeys(t, cfg, "activate", second)
+	runKeys(t, cfg, "retire", "-after", "1m", third)
+
+	list := runKeys(t, cfg, "list")
+	for _, want := range []string{first + "  RS256  retired", second + "  ES256  active", third + "  EdDSA  retired"} {
+		if !strings.Contains(list, want) {
+			t.Fatalf("Expected list to contain %q, got\n%s", want, list)
+		}
+	}
+
+	var jwks jwt.JWKS
+	err = json.Unmarshal([]byte(runKeys(t, cfg, "export-public", "-format", "jwks")), &jwks)
+	if err != nil || len(jwks.Keys) != 3 || jwks.Keys[0].Kid != second {
+		t.Fatalf("Expected a key set led by the active key, got %v", jwks)
+	}
+	pem := runKeys(t, cfg, "export-public", first)
+	if !strings.HasPrefix(pem, "-----BEGIN PUBLIC KEY-----") {
+		t.Fatalf("Expected a PEM public key, got %s", pem)
+	}
+
+	for _, args := range [][]string{
+		{"retire", second},
+		{"activate", "unknown"},
+		{"generate", "-alg", "ES256", "-bits", "4096"},
+		{"generate", "-bits", "1024"},
+		{"import", importPath},
+		{"rotate"},
+	} {
+		err := Keys(cfg, args, &bytes.Buffer{})
+		if err == nil {
+			t.Fatalf("keys %s: expected error, got nil", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestKeysRequireStore(t *testing.T) {
+	cfg := &config.Config{Signer: "file", PrivateKeyPath: "private.pem"}
+	err := Keys(cfg, []string{"list"}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("Expected error without a keyring, got nil")
+	}
+}
+
+type fakeLocker struct {
+	held bool
+}
+
+func (fl *fakeLocker) TryLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	if fl.held {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+func TestKeysLocked(t *testing.T) {
+	for _, cfg := range []*config.Config{
+		{Signer: "postgres"},
+		{Signer: "file", KeyRotationInterval: time.Hour},
+	} {
+		if !lockKeys(cfg) {
+			t.Fatalf("Expected %+v to lock key edits", cfg)
+		}
+	}
+	// DSN has a default, so it does not decide whether to lock
+	if lockKeys(&config.Config{Signer: "file", DSN: "postgres://localhost/oauth"}) {
+		t.Fatal("Expected key edits without rotation not to lock")
+	}
+
+	cfg := &config.Config{Signer: "file", KeyringPath: filepath.Join(t.TempDir(), "keyring.json")}
+	locker := &fakeLocker{held: true}
+	kc := &keyCommand{cfg: cfg, store: keyring.NewFileStore(cfg.KeyringPath, nil), locker: locker, out: &bytes.Buffer{}, now: time.Now()}
+
+	err := kc.generate(context.Background(), []string{"-alg", "ES256"})
+	if err == nil || err.Error() != "keys are being rotated, try again" {
+		t.Fatalf("Expected edit to fail while the lock is held, got %v", err)
+	}
+	_, err = os.Stat(cfg.KeyringPath)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Expected no keyring to be written, got %v", err)
+	}
+
+	locker.held = false
+	err = kc.generate(context.Background(), []string{"-alg", "ES256"})
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	keys, err := keyring.LoadFile(cfg.KeyringPath, nil)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Expected the key to be saved under the lock, got %d keys: %v", len(keys), err)
+	}
+}
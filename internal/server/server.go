@@ -2,14 +2,27 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"expvar"
 	"fmt"
 	"net/http"
 	oauth "oauth/api"
 	"oauth/config"
+	"oauth/internal/app/ciba"
 	"oauth/internal/app/client"
+	"oauth/internal/app/jar"
+	"oauth/internal/app/keystore"
 	"oauth/internal/app/manager"
+	"oauth/internal/app/resource"
+	"oauth/internal/app/rotation"
+	"oauth/internal/app/saml"
+	"oauth/internal/app/subject"
 	"oauth/internal/app/token"
-	"oauth/pkg/rsa"
+	"oauth/pkg/key"
+	"oauth/pkg/keyring"
+	"oauth/pkg/reload"
+	"oauth/pkg/signer"
 	"os"
 	"os/signal"
 	"strings"
@@ -40,12 +53,109 @@ func rootHandler(grpcServer *grpc.Server, otherHandler http.Handler) http.Handle
 	}), &http2.Server{})
 }
 
-func Run(cfg *config.Config) error {
-	key, err := rsa.GetPrivateKey(cfg.PrivateKeyPath)
+// watcher is a key store that reports changes made by other replicas
+type watcher interface {
+	Watch(onChange func(ctx context.Context))
+	Close()
+}
+
+// bootstrapper is a key store that generates a key when it is empty
+type bootstrapper interface {
+	Bootstrap(ctx context.Context, generate func() (*keyring.Key, error)) error
+}
+
+// keyStore returns the store holding the signing keys, or nil when a single
+// key is configured
+func keyStore(cfg *config.Config, dbpool *pgxpool.Pool, passphrase []byte) (keyring.Store, error) {
+	switch {
+	case cfg.Signer == "postgres":
+		kek, err := base64.StdEncoding.DecodeString(cfg.KeyEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KEY_ENCRYPTION_KEY: %s", err)
+		}
+		return keystore.NewRepository(dbpool, kek)
+	case cfg.Signer == "file" && cfg.KeyringPath != "":
+		return keyring.NewFileStore(cfg.KeyringPath, passphrase), nil
+	}
+
+	return nil, nil
+}
+
+// loadKeys returns the single configured signing key as the active key
+func loadKeys(cfg *config.Config, passphrase []byte) ([]*keyring.Key, error) {
+	var s signer.Signer
+	var err error
+	switch cfg.Signer {
+	case "pkcs11":
+		s, err = signer.NewPKCS11Signer(signer.PKCS11Config{
+			Module:     cfg.PKCS11Module,
+			TokenLabel: cfg.PKCS11TokenLabel,
+			PIN:        cfg.PKCS11PIN,
+			KeyLabel:   cfg.PKCS11KeyLabel,
+		})
+	case "kms":
+		s, err = signer.NewKMSSigner(&http.Client{Timeout: 5 * time.Second}, cfg.KMSURL, cfg.KMSKeyID, cfg.KMSToken)
+	case "file":
+		s, err = signer.NewFileSigner(cfg.PrivateKeyPath, passphrase)
+	default:
+		return nil, fmt.Errorf("unknown signer: %s", cfg.Signer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := keyring.NewKey(s, cfg.SigningAlg, keyring.StateActive)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*keyring.Key{k}, nil
+}
+
+// watchFiles reloads file based signing keys and the TLS certificate when
+// their files change. The returned certificate is nil when TLS is disabled.
+func watchFiles(cfg *config.Config, ring *keyring.Keyring, store keyring.Store, passphrase []byte) (*reload.Watcher, *reload.Certificate, error) {
+	w, err := reload.NewWatcher()
 	if err != nil {
-		return fmt.Errorf("unable to setup rsa key pairs: %s", err)
+		return nil, nil, err
 	}
 
+	switch {
+	case cfg.Signer == "file" && cfg.KeyringPath != "":
+		// the manifest and every key file it lists, which change as keys rotate
+		err = w.AddFunc("keyring", store.(*keyring.FileStore).Paths, func() error {
+			return ring.Reload(context.Background(), store)
+		})
+	case cfg.Signer == "file":
+		err = w.Add("signing_key", []string{cfg.PrivateKeyPath}, func() error {
+			keys, err := loadKeys(cfg, passphrase)
+			if err != nil {
+				return err
+			}
+			// the previous key keeps verifying the tokens it signed
+			return ring.Replace(keys[0], time.Now().Add(token.AccessTokenTTL+cfg.KeyRotationGrace))
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cert *reload.Certificate
+	if cfg.TLSCertPath != "" {
+		cert, err = reload.LoadCertificate(cfg.TLSCertPath, cfg.TLSKeyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = w.Add("tls_certificate", []string{cfg.TLSCertPath, cfg.TLSKeyPath}, cert.Reload)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return w, cert, nil
+}
+
+func Run(cfg *config.Config) error {
 	// eventually switch out dbpool for an adapter
 	dbpool, err := pgxpool.Connect(context.Background(), cfg.DSN)
 	if err != nil {
@@ -53,6 +163,80 @@ func Run(cfg *config.Config) error {
 	}
 	defer dbpool.Close()
 
+	passphrase, err := key.ReadPassphrase(cfg.KeyPassphrase, cfg.KeyPassphraseFile)
+	if err != nil {
+		return err
+	}
+	store, err := keyStore(cfg, dbpool, passphrase)
+	if err != nil {
+		return fmt.Errorf("unable to setup key store: %s", err)
+	}
+	// an empty Postgres store gets a newly generated key
+	if b, ok := store.(bootstrapper); ok {
+		err = b.Bootstrap(context.Background(), func() (*keyring.Key, error) {
+			alg := cfg.SigningAlg
+			if alg == "" {
+				alg = "RS256"
+			}
+			private, err := key.Generate(alg)
+			if err != nil {
+				return nil, err
+			}
+			return keyring.NewKey(private, alg, keyring.StateActive)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to setup key store: %s", err)
+		}
+	}
+	var keys []*keyring.Key
+	if store != nil {
+		keys, err = store.Load(context.Background())
+	} else {
+		keys, err = loadKeys(cfg, passphrase)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to setup signing keys: %s", err)
+	}
+	ring, err := keyring.New(keys)
+	if err != nil {
+		return fmt.Errorf("unable to setup keyring: %s", err)
+	}
+
+	if w, ok := store.(watcher); ok {
+		w.Watch(func(ctx context.Context) {
+			err := ring.Reload(ctx, store)
+			if err != nil {
+				fmt.Printf("unable to reload signing keys: %s\n", err)
+			}
+		})
+		defer w.Close()
+	}
+
+	if cfg.KeyRotationInterval > 0 {
+		if store == nil {
+			return fmt.Errorf("key rotation requires KEYRING_PATH or the postgres signer")
+		}
+		rotator := rotation.NewRotator(ring, store, rotation.NewAdvisoryLocker(dbpool), rotation.Policy{
+			Interval:  cfg.KeyRotationInterval,
+			LeadTime:  cfg.KeyRotationLeadTime,
+			Retention: token.AccessTokenTTL + cfg.KeyRotationGrace,
+			Algorithm: cfg.SigningAlg,
+		})
+		err = rotator.Rotate(context.Background(), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to rotate keys: %s", err)
+		}
+		rotator.Start()
+		defer rotator.Close()
+	}
+
+	fileWatcher, cert, err := watchFiles(cfg, ring, store, passphrase)
+	if err != nil {
+		return fmt.Errorf("unable to watch key files: %s", err)
+	}
+	fileWatcher.Start()
+	defer fileWatcher.Close()
+
 	clientRepo, err := client.NewRepository(dbpool)
 	if err != nil {
 		return fmt.Errorf("failed to setup client repo: %s", err)
@@ -64,9 +248,43 @@ func Run(cfg *config.Config) error {
 		return fmt.Errorf("failed to setup token repo: %s", err)
 	}
 	defer tokenRepo.Close()
-	tokenService := token.NewService(tokenRepo, key)
+	resources, err := resource.LoadServers(cfg.ResourceServersDir)
+	if err != nil {
+		return fmt.Errorf("failed to load resource servers: %s", err)
+	}
+	tokenService := token.NewService(tokenRepo, ring, resource.NewService(resources))
+
+	cibaRepo, err := ciba.NewRepository(dbpool)
+	if err != nil {
+		return fmt.Errorf("failed to setup ciba repo: %s", err)
+	}
+	defer cibaRepo.Close()
+	// CIBA stays disabled until a notifier can reach users' devices
+	var notifier ciba.Notifier
+	if cfg.CIBANotifierURL != "" {
+		notifier, err = ciba.NewHTTPNotifier(cfg.CIBANotifierURL, cfg.CIBANotifierToken)
+		if err != nil {
+			return fmt.Errorf("failed to setup ciba notifier: %s", err)
+		}
+	}
+	cibaService := ciba.NewService(cibaRepo, notifier)
+
+	jarService := jar.NewService(cfg.Issuer)
+
+	subjectService := subject.NewService(cfg.PairwiseSalt)
+
+	idps, err := saml.LoadMetadata(cfg.SAMLMetadataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load saml metadata: %s", err)
+	}
+	samlRepo, err := saml.NewRepository(dbpool)
+	if err != nil {
+		return fmt.Errorf("failed to setup saml repo: %s", err)
+	}
+	defer samlRepo.Close()
+	samlService := saml.NewService(samlRepo, idps, cfg.Issuer, fmt.Sprintf("%s/v1/token", cfg.Issuer))
 
-	manager := manager.NewManager(clientService, tokenService)
+	manager := manager.NewManager(clientService, tokenService, cibaService, jarService, subjectService, samlService)
 
 	app := &app{m: manager}
 
@@ -82,8 +300,32 @@ func Run(cfg *config.Config) error {
 		Handler: rootHandler(grpcServer, r),
 	}
 
+	if cert != nil {
+		srv.TLSConfig = &tls.Config{GetCertificate: cert.GetCertificate}
+	}
+
+	// metrics are served apart from the API, on an address that is
+	// loopback only unless ADMIN_ADDR says otherwise
+	var admin *http.Server
+	if cfg.AdminAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		admin = &http.Server{Addr: cfg.AdminAddr, Handler: mux}
+		go func() {
+			err := admin.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				fmt.Println(err)
+			}
+		}()
+	}
+
 	go func() {
-		err := srv.ListenAndServe()
+		var err error
+		if cert != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
 		if err != nil && err != http.ErrServerClosed {
 			fmt.Println(err)
 		}
@@ -96,6 +338,9 @@ func Run(cfg *config.Config) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if admin != nil {
+		admin.Shutdown(ctx)
+	}
 	err = srv.Shutdown(ctx)
 	if err != nil {
 		return err
@@ -105,9 +350,13 @@ func Run(cfg *config.Config) error {
 }
 
 func (a *app) setupRoutes(r chi.Router, version string) {
+	r.Get("/.well-known/jwks.json", a.jwksHandler())
 	r.Route(fmt.Sprintf("/%s", version), func(r chi.Router) {
 		r.Post("/register", a.registerHandler())
 		r.Get("/token", a.tokenHandler())
+		r.Post("/token", a.tokenHandler())
+		r.Post("/bc-authorize", a.backchannelAuthHandler())
+		r.Post("/bc-authorize/callback", a.backchannelCallbackHandler())
 		r.Get("/validate", a.tokenValidationHandler())
 	})
 }
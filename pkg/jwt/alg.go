@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// DefaultAlgorithm returns the signing algorithm used with key when none is configured
+func DefaultAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("unsupported curve: %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	}
+
+	return "", fmt.Errorf("unsupported key type: %T", key)
+}
+
+// CheckAlgorithm returns an error unless alg is an asymmetric signing algorithm
+// that can be used with key. Checking the key type keeps a token from choosing
+// how its own signature is verified.
+func CheckAlgorithm(key crypto.PublicKey, alg string) error {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	ok := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			ok = true
+		}
+	case *ecdsa.PublicKey:
+		m, isECDSA := method.(*jwt.SigningMethodECDSA)
+		ok = isECDSA && m.CurveBits == k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		_, ok = method.(*jwt.SigningMethodEd25519)
+	}
+	if !ok {
+		return fmt.Errorf("algorithm %s cannot be used with %T", alg, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestValidateAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %s", err)
+	}
+
+	tests := []struct {
+		alg string
+		key crypto.Signer
+	}{
+		{"RS256", rsaKey},
+		{"PS256", rsaKey},
+		{"ES256", p256Key},
+		{"ES384", p384Key},
+		{"EdDSA", edKey},
+	}
+
+	for _, tt := range tests {
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(tt.alg), jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		signed, err := token.SignedString(tt.key)
+		if err != nil {
+			t.Fatalf("%s: failed to sign token: %s", tt.alg, err)
+		}
+
+		_, err = NewValidator(tt.key.Public()).Validate(signed)
+		if err != nil {
+			t.Fatalf("%s: failed to validate token: %s", tt.alg, err)
+		}
+
+		jwk, err := NewJWK(tt.key.Public(), "kid", tt.alg)
+		if err != nil {
+			t.Fatalf("%s: failed to create JWK: %s", tt.alg, err)
+		}
+		_, err = NewKeySetValidator(&JWKS{Keys: []JWK{*jwk}}).Validate(signed)
+		if err != nil {
+			t.Fatalf("%s: failed to validate token with key set: %s", tt.alg, err)
+		}
+	}
+}
+
+func TestCheckAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.PublicKey
+		alg  string
+	}{
+		{"HMAC with RSA key", &rsaKey.PublicKey, "HS256"},
+		{"none", &rsaKey.PublicKey, "none"},
+		{"ECDSA with RSA key", &rsaKey.PublicKey, "ES256"},
+		{"curve mismatch", &ecKey.PublicKey, "ES384"},
+		{"RSA with EC key", &ecKey.PublicKey, "RS256"},
+		{"ECDSA with Ed25519 key", edKey, "ES256"},
+	}
+
+	for _, tt := range tests {
+		err := CheckAlgorithm(tt.key, tt.alg)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", tt.name)
+		}
+	}
+
+	for alg, key := range map[string]crypto.PublicKey{"RS256": &rsaKey.PublicKey, "PS256": &rsaKey.PublicKey, "ES256": &ecKey.PublicKey, "EdDSA": edKey} {
+		err := CheckAlgorithm(key, alg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", alg, err)
+		}
+	}
+}
+
+func TestValidateRejectsMismatchedKeyAlgorithm(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	jwk, _ := NewJWK(&privateKey.PublicKey, "kid", "PS256")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	token.Header["kid"] = "kid"
+	signed, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	_, err = NewKeySetValidator(&JWKS{Keys: []JWK{*jwk}}).Validate(signed)
+	if err == nil {
+		t.Fatal("Expected error for algorithm not allowed by key, got nil")
+	}
+}
@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Key management and content encryption algorithms supported for JWE (RFC 7516)
+const (
+	AlgRSAOAEP256 = "RSA-OAEP-256"
+	AlgECDHES     = "ECDH-ES"
+	EncA256GCM    = "A256GCM"
+)
+
+// a256gcmKeySize is the content encryption key size of A256GCM in bytes
+const a256gcmKeySize = 32
+
+// jweHeader is the protected header of an encrypted token
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+	Epk *JWK   `json:"epk,omitempty"`
+}
+
+// CheckEncryptionAlgorithm returns an error unless alg and enc are supported
+// and alg can be used with key
+func CheckEncryptionAlgorithm(key crypto.PublicKey, alg string, enc string) error {
+	if enc != EncA256GCM {
+		return fmt.Errorf("unsupported content encryption: %s", enc)
+	}
+
+	ok := false
+	switch alg {
+	case AlgRSAOAEP256:
+		_, ok = key.(*rsa.PublicKey)
+	case AlgECDHES:
+		_, ok = key.(*ecdsa.PublicKey)
+	default:
+		return fmt.Errorf("unsupported key management algorithm: %s", alg)
+	}
+	if !ok {
+		return fmt.Errorf("algorithm %s cannot be used with %T", alg, key)
+	}
+
+	return nil
+}
+
+// EncryptionKey returns the first key in s that can encrypt with alg. Only
+// keys marked with use "enc" qualify, so a signing key is never also used
+// for encryption.
+func (s *JWKS) EncryptionKey(alg string) (*JWK, error) {
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if k.Use != "enc" || (k.Alg != "" && k.Alg != alg) {
+			continue
+		}
+		key, err := k.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		if CheckEncryptionAlgorithm(key, alg, EncA256GCM) == nil {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no key for %s", alg)
+}
+
+// Encrypt wraps a signed token in a JWE encrypted to key, producing a nested
+// JWT (RFC 7519 section 5.2) that only the holder of the private key can read
+func Encrypt(token string, alg string, enc string, key *JWK) (string, error) {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	err = CheckEncryptionAlgorithm(pub, alg, enc)
+	if err != nil {
+		return "", err
+	}
+
+	header := jweHeader{Alg: alg, Enc: enc, Kid: key.Kid, Cty: "JWT"}
+	var cek, encryptedKey []byte
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		cek = make([]byte, a256gcmKeySize)
+		_, err = rand.Read(cek)
+		if err != nil {
+			return "", err
+		}
+		encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, k, cek, nil)
+		if err != nil {
+			return "", fmt.Errorf("unable to encrypt key: %s", err)
+		}
+	case *ecdsa.PublicKey:
+		ephemeral, err := ecdsa.GenerateKey(k.Curve, rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		header.Epk, err = NewJWK(&ephemeral.PublicKey, "", "")
+		if err != nil {
+			return "", err
+		}
+		header.Epk.Use = ""
+		cek = deriveECDHES(ephemeral, k, enc)
+	}
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := jwt.EncodeSegment(h)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(token), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		jwt.EncodeSegment(encryptedKey),
+		jwt.EncodeSegment(iv),
+		jwt.EncodeSegment(ciphertext),
+		jwt.EncodeSegment(tag),
+	}, "."), nil
+}
+
+// Decrypt decrypts a JWE in compact serialization with key, an *rsa.PrivateKey
+// or *ecdsa.PrivateKey, and returns its plaintext. The key management algorithm
+// in the header must match the type of key.
+func Decrypt(token string, key crypto.PrivateKey) ([]byte, error) {
+	header, segments, err := parseJWE(token)
+	if err != nil {
+		return nil, err
+	}
+	if header.Enc != EncA256GCM {
+		return nil, fmt.Errorf("unsupported content encryption: %s", header.Enc)
+	}
+
+	var cek []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if header.Alg != AlgRSAOAEP256 {
+			return nil, fmt.Errorf("algorithm %s cannot be used with %T", header.Alg, key)
+		}
+		cek, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, k, segments[1], nil)
+		if err != nil || len(cek) != a256gcmKeySize {
+			return nil, fmt.Errorf("unable to decrypt token")
+		}
+	case *ecdsa.PrivateKey:
+		if header.Alg != AlgECDHES {
+			return nil, fmt.Errorf("algorithm %s cannot be used with %T", header.Alg, key)
+		}
+		if len(segments[1]) != 0 {
+			return nil, fmt.Errorf("invalid token: unexpected encrypted key")
+		}
+		if header.Epk == nil || header.Epk.Kty != "EC" {
+			return nil, fmt.Errorf("invalid token: missing ephemeral key")
+		}
+		epk, err := header.Epk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		ephemeral := epk.(*ecdsa.PublicKey)
+		if ephemeral.Curve != k.Curve {
+			return nil, fmt.Errorf("invalid token: ephemeral key is not on %s", k.Curve.Params().Name)
+		}
+		cek = deriveECDHES(k, ephemeral, header.Enc)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments[2]) != gcm.NonceSize() || len(segments[4]) != gcm.Overhead() {
+		return nil, fmt.Errorf("invalid token")
+	}
+	protected := strings.SplitN(token, ".", 2)[0]
+	plaintext, err := gcm.Open(nil, segments[2], append(segments[3], segments[4]...), []byte(protected))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt token")
+	}
+
+	return plaintext, nil
+}
+
+// ValidateEncrypted decrypts a nested JWT with key and validates the signed
+// token inside it, so a resource server handles encrypted tokens in one call
+func (v *Validator) ValidateEncrypted(token string, key crypto.PrivateKey) (*jwt.Token, error) {
+	header, _, err := parseJWE(token)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(header.Cty, "JWT") {
+		return nil, fmt.Errorf("invalid token: encrypted content is not a JWT")
+	}
+
+	signed, err := Decrypt(token, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Validate(string(signed))
+}
+
+func parseJWE(token string) (*jweHeader, [][]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, nil, fmt.Errorf("invalid token: expected 5 segments, got %d", len(parts))
+	}
+
+	segments := make([][]byte, len(parts))
+	for i, p := range parts {
+		b, err := jwt.DecodeSegment(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid token: %s", err)
+		}
+		segments[i] = b
+	}
+
+	var header jweHeader
+	err := json.Unmarshal(segments[0], &header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid token header: %s", err)
+	}
+
+	return &header, segments, nil
+}
+
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveECDHES derives the content encryption key for direct key agreement
+// with the Concat KDF (RFC 7518 section 4.6.2). No PartyUInfo or PartyVInfo is
+// sent, so both are empty.
+func deriveECDHES(private *ecdsa.PrivateKey, public *ecdsa.PublicKey, enc string) []byte {
+	size := (private.Curve.Params().BitSize + 7) / 8
+	x, _ := private.Curve.ScalarMult(public.X, public.Y, private.D.Bytes())
+	z := x.FillBytes(make([]byte, size))
+
+	var otherInfo []byte
+	otherInfo = appendLengthPrefixed(otherInfo, []byte(enc))
+	otherInfo = appendLengthPrefixed(otherInfo, nil)
+	otherInfo = appendLengthPrefixed(otherInfo, nil)
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, a256gcmKeySize*8)
+
+	// a single round of SHA-256 yields the 256 bits A256GCM needs
+	h := sha256.New()
+	h.Write([]byte{0, 0, 0, 1})
+	h.Write(z)
+	h.Write(otherInfo)
+	return h.Sum(nil)
+}
+
+func appendLengthPrefixed(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
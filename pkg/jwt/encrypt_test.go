@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestValidateEncrypted(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %s", err)
+	}
+	signed, err := Sign(&jwt.StandardClaims{Subject: "user", ExpiresAt: time.Now().Add(time.Minute).Unix()}, "ES256", "", signingKey)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+
+	tests := []struct {
+		alg     string
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+	}{
+		{AlgRSAOAEP256, rsaKey, &rsaKey.PublicKey},
+		{AlgECDHES, ecKey, &ecKey.PublicKey},
+	}
+
+	for _, tt := range tests {
+		jwk, err := NewJWK(tt.public, "resource-server", "")
+		if err != nil {
+			t.Fatalf("%s: failed to encode key: %s", tt.alg, err)
+		}
+		jwk.Use = "enc"
+
+		token, err := Encrypt(signed, tt.alg, EncA256GCM, jwk)
+		if err != nil {
+			t.Fatalf("%s: failed to encrypt token: %s", tt.alg, err)
+		}
+		if strings.Contains(token, strings.Split(signed, ".")[1]) {
+			t.Fatalf("%s: claims readable in encrypted token", tt.alg)
+		}
+
+		parsed, err := NewValidator(&signingKey.PublicKey).ValidateEncrypted(token, tt.private)
+		if err != nil {
+			t.Fatalf("%s: failed to validate token: %s", tt.alg, err)
+		}
+		if parsed.Claims.(*jwt.StandardClaims).Subject != "user" {
+			t.Fatalf("%s: unexpected claims %v", tt.alg, parsed.Claims)
+		}
+
+		// flipping a ciphertext bit must fail authentication
+		parts := strings.Split(token, ".")
+		b, _ := jwt.DecodeSegment(parts[3])
+		b[0] ^= 1
+		parts[3] = jwt.EncodeSegment(b)
+		_, err = Decrypt(strings.Join(parts, "."), tt.private)
+		if err == nil {
+			t.Fatalf("%s: expected error for tampered token, got nil", tt.alg)
+		}
+	}
+
+	// the key management algorithm has to match the decryption key
+	jwk, _ := NewJWK(&rsaKey.PublicKey, "", "")
+	token, _ := Encrypt(signed, AlgRSAOAEP256, EncA256GCM, jwk)
+	_, err = Decrypt(token, ecKey)
+	if err == nil {
+		t.Fatal("Expected error decrypting with the wrong key type, got nil")
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	_, keys := testKeySet(t)
+	_, err := keys.EncryptionKey(AlgECDHES)
+	if err == nil {
+		t.Fatal("Expected signing key to be skipped, got nil")
+	}
+
+	// a key without use could be a signing key and is skipped as well
+	keys.Keys[0].Use = ""
+	keys.Keys[0].Alg = ""
+	_, err = keys.EncryptionKey(AlgECDHES)
+	if err == nil {
+		t.Fatal("Expected key without use to be skipped, got nil")
+	}
+
+	keys.Keys[0].Use = "enc"
+	k, err := keys.EncryptionKey(AlgECDHES)
+	if err != nil || k.Kid != "client-key" {
+		t.Fatalf("Expected client-key, got %v", err)
+	}
+	_, err = keys.EncryptionKey(AlgRSAOAEP256)
+	if err == nil {
+		t.Fatal("Expected error for RSA-OAEP-256 with an EC key, got nil")
+	}
+}
@@ -0,0 +1,105 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestThumbprint(t *testing.T) {
+	// example from RFC 7638 section 3.1
+	jwk := &JWK{
+		Kty: "RSA",
+		Kid: "2011-04-29",
+		Alg: "RS256",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn" +
+			"64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbI" +
+			"SD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatalf("Failed to compute thumbprint: %s", err)
+	}
+
+	if thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("Unexpected thumbprint: %s", thumbprint)
+	}
+}
+
+func TestThumbprintOKP(t *testing.T) {
+	// example from RFC 8037 appendix A.3
+	jwk := &JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+	}
+
+	_, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("Failed to decode key: %s", err)
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatalf("Failed to compute thumbprint: %s", err)
+	}
+
+	if thumbprint != "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k" {
+		t.Fatalf("Unexpected thumbprint: %s", thumbprint)
+	}
+}
+
+func TestValidateWithKeySet(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+	unknownKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	oldJWK, _ := NewJWK(&oldKey.PublicKey, "old", "RS256")
+	newJWK, _ := NewJWK(&newKey.PublicKey, "new", "RS256")
+	validator := NewKeySetValidator(&JWKS{Keys: []JWK{*oldJWK, *newJWK}})
+
+	sign := func(key *rsa.PrivateKey, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()})
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %s", err)
+		}
+		return s
+	}
+
+	for _, kid := range []string{"old", "new"} {
+		key := oldKey
+		if kid == "new" {
+			key = newKey
+		}
+		_, err := validator.Validate(sign(key, kid))
+		if err != nil {
+			t.Fatalf("Failed to validate token signed by %s key: %s", kid, err)
+		}
+	}
+
+	_, err = validator.Validate(sign(unknownKey, "unknown"))
+	if err == nil {
+		t.Fatal("Expected error for unknown kid, got nil")
+	}
+
+	_, err = validator.Validate(sign(unknownKey, "new"))
+	if err == nil {
+		t.Fatal("Expected error for wrong key, got nil")
+	}
+}
@@ -1,7 +1,7 @@
 package jwt
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"fmt"
 	"time"
 
@@ -10,24 +10,33 @@ import (
 
 // Validator -
 type Validator struct {
-	key *rsa.PublicKey
+	key  crypto.PublicKey
+	keys *JWKS
 }
 
 // NewValidator -
-func NewValidator(key *rsa.PublicKey) *Validator {
+func NewValidator(key crypto.PublicKey) *Validator {
 	return &Validator{
 		key: key,
 	}
 }
 
+// NewKeySetValidator returns a Validator that picks the verification key from
+// keys using the kid in the token header, so tokens signed by any key in the
+// set are accepted
+func NewKeySetValidator(keys *JWKS) *Validator {
+	return &Validator{
+		keys: keys,
+	}
+}
+
 // Validate validates a JWT
 func (v *Validator) Validate(token string) (*jwt.Token, error) {
 	t, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected method: %s", jwtToken.Header["alg"])
+		if v.keys == nil {
+			return v.key, CheckAlgorithm(v.key, jwtToken.Method.Alg())
 		}
-
-		return v.key, nil
+		return v.keys.verificationKey(jwtToken)
 	})
 
 	if err != nil {
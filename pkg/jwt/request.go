@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// RequestObjectValidator validates JWT-secured authorization requests (RFC 9101)
+// signed by a client
+type RequestObjectValidator struct {
+	keys     *JWKS
+	clientID string
+	audience string
+}
+
+// NewRequestObjectValidator returns a validator for request objects signed with
+// one of the client's keys and addressed to audience
+func NewRequestObjectValidator(keys *JWKS, clientID string, audience string) *RequestObjectValidator {
+	return &RequestObjectValidator{
+		keys:     keys,
+		clientID: clientID,
+		audience: audience,
+	}
+}
+
+// Validate checks the signature, iss, aud and exp of a request object and
+// returns its claims
+func (v *RequestObjectValidator) Validate(request string) (jwt.MapClaims, error) {
+	t, err := jwt.Parse(request, v.keys.verificationKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request object: %s", err)
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid claims")
+	}
+
+	if !claims.VerifyIssuer(v.clientID, true) {
+		return nil, fmt.Errorf("request object has unexpected issuer")
+	}
+	if !claims.VerifyAudience(v.audience, true) {
+		return nil, fmt.Errorf("request object has unexpected audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("request object has expired")
+	}
+	if id, ok := claims["client_id"]; ok && id != v.clientID {
+		return nil, fmt.Errorf("request object has unexpected client_id")
+	}
+
+	return claims, nil
+}
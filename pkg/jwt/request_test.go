@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func testKeySet(t *testing.T) (*ecdsa.PrivateKey, *JWKS) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	jwk, err := NewJWK(&privateKey.PublicKey, "client-key", "ES256")
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+
+	data, err := json.Marshal(JWKS{Keys: []JWK{*jwk}})
+	if err != nil {
+		t.Fatalf("Failed to marshal key set: %s", err)
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("Failed to parse key set: %s", err)
+	}
+
+	return privateKey, keys
+}
+
+func signRequestObject(t *testing.T, key interface{}, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign request object: %s", err)
+	}
+	return s
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	jwk, err := NewJWK(&privateKey.PublicKey, "kid", "RS256")
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+
+	key, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("Failed to decode key: %s", err)
+	}
+
+	if !privateKey.PublicKey.Equal(key) {
+		t.Fatal("Decoded key does not match")
+	}
+}
+
+func TestValidateRequestObject(t *testing.T) {
+	privateKey, keys := testKeySet(t)
+	validator := NewRequestObjectValidator(keys, "client", "https://issuer")
+
+	request := signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", jwt.MapClaims{
+		"iss":        "client",
+		"aud":        "https://issuer",
+		"exp":        time.Now().Add(time.Minute).Unix(),
+		"scope":      "openid",
+		"login_hint": "user",
+	})
+
+	claims, err := validator.Validate(request)
+	if err != nil {
+		t.Fatalf("Failed to validate request object: %s", err)
+	}
+
+	if claims["login_hint"] != "user" {
+		t.Fatalf("Unexpected login_hint: %v", claims["login_hint"])
+	}
+}
+
+func TestValidateRequestObjectRejected(t *testing.T) {
+	privateKey, keys := testKeySet(t)
+	otherKey, _ := testKeySet(t)
+	validator := NewRequestObjectValidator(keys, "client", "https://issuer")
+
+	valid := jwt.MapClaims{
+		"iss": "client",
+		"aud": "https://issuer",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	with := func(name string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		request string
+	}{
+		{"wrong issuer", signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", with("iss", "other"))},
+		{"wrong audience", signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", with("aud", "https://other"))},
+		{"missing expiry", signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", with("exp", nil))},
+		{"expired", signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", with("exp", time.Now().Add(-time.Minute).Unix()))},
+		{"mismatched client_id", signRequestObject(t, privateKey, jwt.SigningMethodES256, "client-key", with("client_id", "other"))},
+		{"unknown kid", signRequestObject(t, privateKey, jwt.SigningMethodES256, "other-key", valid)},
+		{"wrong key", signRequestObject(t, otherKey, jwt.SigningMethodES256, "client-key", valid)},
+		{"symmetric algorithm", signRequestObject(t, []byte("secret"), jwt.SigningMethodHS256, "client-key", valid)},
+	}
+
+	for _, tt := range tests {
+		_, err := validator.Validate(tt.request)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
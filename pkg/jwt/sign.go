@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Sign signs claims with alg and returns the compact serialization. signer
+// only has to implement crypto.Signer, so the private key can live in an HSM
+// or a remote KMS.
+func Sign(claims jwt.Claims, alg string, kid string, signer crypto.Signer) (string, error) {
+	err := CheckAlgorithm(signer.Public(), alg)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+
+	var sig []byte
+	switch m := token.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		sig, err = signer.Sign(rand.Reader, digest(m.Hash, signingString), m.Hash)
+	case *jwt.SigningMethodRSAPSS:
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: m.Hash}
+		sig, err = signer.Sign(rand.Reader, digest(m.Hash, signingString), opts)
+	case *jwt.SigningMethodECDSA:
+		sig, err = signer.Sign(rand.Reader, digest(m.Hash, signingString), m.Hash)
+		if err == nil {
+			sig, err = rawECDSASignature(sig, m.KeySize)
+		}
+	case *jwt.SigningMethodEd25519:
+		sig, err = signer.Sign(rand.Reader, []byte(signingString), crypto.Hash(0))
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to sign token: %s", err)
+	}
+
+	return strings.Join([]string{signingString, jwt.EncodeSegment(sig)}, "."), nil
+}
+
+func digest(hash crypto.Hash, signingString string) []byte {
+	h := hash.New()
+	h.Write([]byte(signingString))
+	return h.Sum(nil)
+}
+
+// rawECDSASignature converts an ASN.1 encoded ECDSA signature into the fixed
+// size r || s form used by JWS (RFC 7518 section 3.4)
+func rawECDSASignature(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("invalid ECDSA signature")
+	}
+	if sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, fmt.Errorf("invalid ECDSA signature")
+	}
+
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
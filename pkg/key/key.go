@@ -0,0 +1,230 @@
+package key
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+)
+
+// GetPrivateKey reads a PEM encoded RSA, ECDSA or Ed25519 private key.
+// passphrase decrypts encrypted PKCS #8 keys and is ignored otherwise.
+func GetPrivateKey(privateKeyPath string, passphrase []byte) (crypto.Signer, error) {
+	keyFile, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %s", err)
+	}
+	privateKey, err := ParsePrivateKey(keyFile, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unabled to parse private key %s: %s", privateKeyPath, err)
+	}
+
+	return privateKey, nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #1 RSA key, SEC1 EC key or an
+// unencrypted or encrypted PKCS #8 private key, and rejects weak keys
+func ParsePrivateKey(data []byte, passphrase []byte) (crypto.Signer, error) {
+	var k any
+	var err error
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("key must be PEM encoded")
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			if _, ok := block.Headers["DEK-Info"]; ok {
+				return nil, fmt.Errorf("legacy encrypted PEM is not supported, convert the key to encrypted PKCS #8 with openssl pkcs8 -topk8")
+			}
+			k, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			if _, ok := block.Headers["DEK-Info"]; ok {
+				return nil, fmt.Errorf("legacy encrypted PEM is not supported, convert the key to encrypted PKCS #8 with openssl pkcs8 -topk8")
+			}
+			k, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			if len(passphrase) == 0 {
+				return nil, fmt.Errorf("key is encrypted but no passphrase is configured")
+			}
+			k, err = decryptPKCS8(block.Bytes, passphrase)
+		case "EC PARAMETERS":
+			// written ahead of the key by openssl ecparam -genkey
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported PEM block: %s", block.Type)
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var signer crypto.Signer
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		signer = k
+	case *ecdsa.PrivateKey:
+		signer = k
+	case ed25519.PrivateKey:
+		signer = k
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", k)
+	}
+
+	err = CheckStrength(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// CheckStrength returns an error for keys too weak to sign tokens: RSA keys
+// under 2048 bits or with a small exponent and EC keys under 256 bits
+func CheckStrength(key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return fmt.Errorf("weak key: RSA key has %d bits, at least 2048 are required", k.N.BitLen())
+		}
+		if k.E < 3 || k.E%2 == 0 {
+			return fmt.Errorf("weak key: RSA public exponent %d is invalid", k.E)
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().BitSize < 256 {
+			return fmt.Errorf("weak key: curve %s is under 256 bits", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+	default:
+		return fmt.Errorf("unsupported key type: %T", key)
+	}
+
+	return nil
+}
+
+// ReadPassphrase returns value, or when it is empty the contents of path
+// without the trailing newline. A path of "-" reads the first line of stdin.
+func ReadPassphrase(value string, path string) ([]byte, error) {
+	if value != "" || path == "" {
+		return []byte(value), nil
+	}
+
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = bufio.NewReader(os.Stdin).ReadBytes('\n')
+		if err == io.EOF {
+			err = nil
+		}
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passphrase: %s", err)
+	}
+
+	return bytes.TrimRight(b, "\r\n"), nil
+}
+
+// Generate returns a new private key for signing with alg
+func Generate(alg string) (crypto.Signer, error) {
+	return GenerateSize(alg, 0)
+}
+
+// GenerateSize returns a new private key for signing with alg. bits sets the
+// RSA modulus size and defaults to 2048, the curve of EC and Ed25519 keys
+// follows from alg so bits must be 0 for them.
+func GenerateSize(alg string, bits int) (crypto.Signer, error) {
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		if bits == 0 {
+			bits = 2048
+		}
+		if bits < 2048 {
+			return nil, fmt.Errorf("RSA keys must be at least 2048 bits, got %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	}
+	if bits != 0 {
+		return nil, fmt.Errorf("key size cannot be set for %s", alg)
+	}
+
+	switch alg {
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		return k, err
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+}
+
+// PrivateBytes PEM encodes p. With a passphrase the key is written as
+// encrypted PKCS #8, otherwise RSA keys keep the PKCS #1 encoding and every
+// other key type is encoded as PKCS #8.
+func PrivateBytes(p crypto.Signer, passphrase []byte) ([]byte, error) {
+	if k, ok := p.(*rsa.PrivateKey); ok && len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(k),
+		}), nil
+	}
+
+	b, err := x509.MarshalPKCS8PrivateKey(p)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding private key: %s", err)
+	}
+	if len(passphrase) != 0 {
+		b, err = encryptPKCS8(b, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting private key: %s", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "ENCRYPTED PRIVATE KEY",
+			Bytes: b,
+		}), nil
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: b,
+	}), nil
+}
+
+// PublicBytes encodes p as a PEM PKIX SubjectPublicKeyInfo
+func PublicBytes(p crypto.PublicKey) ([]byte, error) {
+	der, err := PublicDER(p)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}), nil
+}
+
+// PublicDER encodes p as a DER PKIX SubjectPublicKeyInfo
+func PublicDER(p crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(p)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding public key: %s", err)
+	}
+	return der, nil
+}
@@ -0,0 +1,133 @@
+package key
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetPrivateKey(t *testing.T) {
+	tests := []struct {
+		path       string
+		passphrase string
+		check      func(any) bool
+	}{
+		{"testdata/rsa_encrypted.pem", "secret", func(k any) bool { _, ok := k.(*rsa.PrivateKey); return ok }},
+		{"testdata/ec_sec1.pem", "", func(k any) bool { _, ok := k.(*ecdsa.PrivateKey); return ok }},
+		{"testdata/ed25519_encrypted.pem", "secret", func(k any) bool { _, ok := k.(ed25519.PrivateKey); return ok }},
+		{"../../certificates/private.pem", "", func(k any) bool { _, ok := k.(*rsa.PrivateKey); return ok }},
+	}
+
+	for _, tt := range tests {
+		k, err := GetPrivateKey(tt.path, []byte(tt.passphrase))
+		if err != nil {
+			t.Fatalf("%s: failed to read key: %s", tt.path, err)
+		}
+		if !tt.check(k) {
+			t.Fatalf("%s: unexpected key type %T", tt.path, k)
+		}
+	}
+}
+
+func TestGetPrivateKeyErrors(t *testing.T) {
+	tests := []struct {
+		path       string
+		passphrase string
+		want       string
+	}{
+		{"testdata/rsa_encrypted.pem", "", "no passphrase"},
+		{"testdata/rsa_encrypted.pem", "wrong", "incorrect passphrase"},
+		{"testdata/ec_pbes1.pem", "secret", "only PBES2"},
+		{"testdata/rsa_1024.pem", "", "RSA key has 1024 bits"},
+	}
+
+	for _, tt := range tests {
+		_, err := GetPrivateKey(tt.path, []byte(tt.passphrase))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.path, tt.want, err)
+		}
+	}
+}
+
+func TestPrivateBytesEncrypted(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		k, err := Generate(alg)
+		if err != nil {
+			t.Fatalf("%s: failed to generate key: %s", alg, err)
+		}
+
+		b, err := PrivateBytes(k, []byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: failed to encode key: %s", alg, err)
+		}
+		if !strings.Contains(string(b), "ENCRYPTED PRIVATE KEY") {
+			t.Fatalf("%s: expected an encrypted PKCS #8 key", alg)
+		}
+
+		parsed, err := ParsePrivateKey(b, []byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: failed to parse key: %s", alg, err)
+		}
+		if !parsed.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(k.Public()) {
+			t.Fatalf("%s: parsed key does not match", alg)
+		}
+	}
+}
+
+func TestPublicBytes(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES384", "EdDSA"} {
+		k, err := Generate(alg)
+		if err != nil {
+			t.Fatalf("%s: failed to generate key: %s", alg, err)
+		}
+
+		b, err := PublicBytes(k.Public())
+		if err != nil {
+			t.Fatalf("%s: failed to encode key: %s", alg, err)
+		}
+		block, _ := pem.Decode(b)
+		if block == nil || block.Type != "PUBLIC KEY" {
+			t.Fatalf("%s: expected a PUBLIC KEY block", alg)
+		}
+
+		der, err := PublicDER(k.Public())
+		if err != nil {
+			t.Fatalf("%s: failed to encode key: %s", alg, err)
+		}
+		if string(der) != string(block.Bytes) {
+			t.Fatalf("%s: PEM and DER encodings differ", alg)
+		}
+		parsed, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			t.Fatalf("%s: failed to parse key: %s", alg, err)
+		}
+		if !parsed.(interface{ Equal(crypto.PublicKey) bool }).Equal(k.Public()) {
+			t.Fatalf("%s: parsed key does not match", alg)
+		}
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passphrase")
+	err := os.WriteFile(path, []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write passphrase: %s", err)
+	}
+
+	p, err := ReadPassphrase("", path)
+	if err != nil || string(p) != "from-file" {
+		t.Fatalf("Expected passphrase from file, got %q %v", p, err)
+	}
+
+	p, err = ReadPassphrase("from-env", path)
+	if err != nil || string(p) != "from-env" {
+		t.Fatalf("Expected passphrase from env, got %q %v", p, err)
+	}
+}
@@ -0,0 +1,218 @@
+package keyring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"oauth/pkg/key"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// manifestKey describes one key in a keyring manifest. Relative paths are
+// resolved against the directory of the manifest.
+type manifestKey struct {
+	ID          string    `json:"kid"`
+	Alg         string    `json:"alg,omitempty"`
+	State       string    `json:"state"`
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatedAt time.Time `json:"activated_at"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Path        string    `json:"path"`
+}
+
+type manifest struct {
+	Keys []manifestKey `json:"keys"`
+}
+
+// LoadFile reads a JSON keyring manifest and the PEM private keys it
+// references, decrypting encrypted keys with passphrase
+func LoadFile(path string, passphrase []byte) ([]*Key, error) {
+	keys, _, err := loadManifest(path, passphrase)
+	return keys, err
+}
+
+func readManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keyring manifest: %s", err)
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse keyring manifest: %s", err)
+	}
+
+	return &m, nil
+}
+
+// resolvePath resolves the path of a key listed in the manifest at manifestPath
+func resolvePath(manifestPath string, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(manifestPath), p)
+}
+
+func loadManifest(path string, passphrase []byte) ([]*Key, map[string]string, error) {
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]*Key, 0, len(m.Keys))
+	paths := make(map[string]string, len(m.Keys))
+	for _, mk := range m.Keys {
+		keyPath := resolvePath(path, mk.Path)
+
+		private, err := key.GetPrivateKey(keyPath, passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		k, err := NewKey(private, mk.Alg, mk.State)
+		if err != nil {
+			return nil, nil, fmt.Errorf("key %s: %s", keyPath, err)
+		}
+		if mk.ID != "" {
+			k.ID = mk.ID
+		}
+		k.CreatedAt = mk.CreatedAt
+		k.ActivatedAt = mk.ActivatedAt
+		k.NotBefore = mk.NotBefore
+		k.NotAfter = mk.NotAfter
+		keys = append(keys, k)
+		paths[k.ID] = mk.Path
+	}
+
+	return keys, paths, nil
+}
+
+// FileStore keeps keys as PEM files next to a JSON manifest. With a
+// passphrase new keys are written as encrypted PKCS #8.
+type FileStore struct {
+	mu         sync.Mutex
+	path       string
+	passphrase []byte
+}
+
+// NewFileStore returns a Store backed by the manifest at path
+func NewFileStore(path string, passphrase []byte) *FileStore {
+	return &FileStore{path: path, passphrase: passphrase}
+}
+
+// Load reads the manifest and every key it references
+func (fs *FileStore) Load(ctx context.Context) ([]*Key, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return LoadFile(fs.path, fs.passphrase)
+}
+
+// Paths returns the manifest path followed by the paths of the key files it
+// currently lists
+func (fs *FileStore) Paths() ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	m, err := readManifest(fs.path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{fs.path}
+	for _, mk := range m.Keys {
+		paths = append(paths, resolvePath(fs.path, mk.Path))
+	}
+	return paths, nil
+}
+
+// Save writes new keys as <kid>.pem next to the manifest, then replaces the
+// manifest. Files of keys that are no longer listed are removed.
+func (fs *FileStore) Save(ctx context.Context, keys []*Key) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	_, paths, err := loadManifest(fs.path, fs.passphrase)
+	if err != nil {
+		if _, statErr := os.Stat(fs.path); !os.IsNotExist(statErr) {
+			return err
+		}
+		paths = map[string]string{}
+	}
+
+	dir := filepath.Dir(fs.path)
+	m := manifest{Keys: make([]manifestKey, 0, len(keys))}
+	for _, k := range keys {
+		p, ok := paths[k.ID]
+		if !ok {
+			p = k.ID + ".pem"
+			b, err := key.PrivateBytes(k.Signer, fs.passphrase)
+			if err != nil {
+				return err
+			}
+			err = writeFile(filepath.Join(dir, p), b)
+			if err != nil {
+				return fmt.Errorf("unable to write key %s: %s", k.ID, err)
+			}
+		}
+		delete(paths, k.ID)
+
+		m.Keys = append(m.Keys, manifestKey{
+			ID:          k.ID,
+			Alg:         k.Alg,
+			State:       k.State,
+			CreatedAt:   k.CreatedAt,
+			ActivatedAt: k.ActivatedAt,
+			NotBefore:   k.NotBefore,
+			NotAfter:    k.NotAfter,
+			Path:        p,
+		})
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeFile(fs.path, data)
+	if err != nil {
+		return fmt.Errorf("unable to write keyring manifest: %s", err)
+	}
+
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			os.Remove(filepath.Join(dir, p))
+		}
+	}
+
+	return nil
+}
+
+// writeFile replaces path atomically so readers never see a partial file
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0600)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
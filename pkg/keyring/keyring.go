@@ -0,0 +1,252 @@
+package keyring
+
+import (
+	"crypto"
+	"fmt"
+	"oauth/pkg/jwt"
+	"oauth/pkg/key"
+	"oauth/pkg/signer"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States a key moves through. A pending key is published for verification
+// before it starts signing, the active key signs new tokens and a retired key
+// only verifies tokens issued before it was replaced.
+const (
+	StatePending = "pending"
+	StateActive  = "active"
+	StateRetired = "retired"
+)
+
+// Key is a signing key together with its kid, algorithm, state and validity
+// window. A zero NotBefore or NotAfter leaves that side of the window open.
+type Key struct {
+	ID          string
+	Alg         string
+	State       string
+	CreatedAt   time.Time
+	ActivatedAt time.Time
+	NotBefore   time.Time
+	NotAfter    time.Time
+	Signer      signer.Signer
+}
+
+// NewKey returns a key in state whose kid is the JWK thumbprint of its public
+// key. An empty alg selects the default algorithm for the key type.
+func NewKey(private signer.Signer, alg string, state string) (*Key, error) {
+	var err error
+	if alg == "" {
+		alg, err = jwt.DefaultAlgorithm(private.Public())
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = jwt.CheckAlgorithm(private.Public(), alg)
+	if err != nil {
+		return nil, err
+	}
+	err = key.CheckStrength(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := jwt.NewJWK(private.Public(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: kid, Alg: alg, State: state, CreatedAt: time.Now(), Signer: private}, nil
+}
+
+// Public returns the public half of k
+func (k *Key) Public() crypto.PublicKey {
+	return k.Signer.Public()
+}
+
+// ValidAt reports whether t falls inside the validity window of k
+func (k *Key) ValidAt(t time.Time) bool {
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && !t.Before(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// JWK returns the public half of k as a JWK
+func (k *Key) JWK() (*jwt.JWK, error) {
+	return jwt.NewJWK(k.Public(), k.ID, k.Alg)
+}
+
+// Keyring holds one active signing key and any number of verification-only keys
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+// New returns a keyring holding keys
+func New(keys []*Key) (*Keyring, error) {
+	err := validate(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{keys: keys}, nil
+}
+
+func validate(keys []*Key) error {
+	active := 0
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" {
+			return fmt.Errorf("key is missing a kid")
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("duplicate kid: %s", k.ID)
+		}
+		seen[k.ID] = true
+
+		switch k.State {
+		case StateActive:
+			active++
+		case StatePending, StateRetired:
+		default:
+			return fmt.Errorf("key %s has unknown state: %s", k.ID, k.State)
+		}
+
+		if k.Signer == nil {
+			return fmt.Errorf("key %s has no signer", k.ID)
+		}
+		if !k.NotBefore.IsZero() && !k.NotAfter.IsZero() && !k.NotBefore.Before(k.NotAfter) {
+			return fmt.Errorf("key %s has an empty validity window", k.ID)
+		}
+	}
+
+	if active != 1 {
+		return fmt.Errorf("keyring must have exactly one active key, found %d", active)
+	}
+
+	return nil
+}
+
+// Set atomically replaces every key in the keyring
+func (kr *Keyring) Set(keys []*Key) error {
+	err := validate(keys)
+	if err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = keys
+	return nil
+}
+
+// Replace atomically makes k the active key. The previous active key is
+// retired but keeps verifying tokens until retireAt, and retired keys whose
+// window has passed are dropped.
+func (kr *Keyring) Replace(k *Key, retireAt time.Time) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]*Key, 0, len(kr.keys)+1)
+	for _, old := range kr.keys {
+		if old.ID == k.ID {
+			continue
+		}
+		if old.State == StateActive {
+			retired := *old
+			retired.State = StateRetired
+			retired.NotAfter = retireAt
+			old = &retired
+		}
+		if old.State == StateRetired && !old.ValidAt(now) {
+			continue
+		}
+		keys = append(keys, old)
+	}
+	active := *k
+	active.State = StateActive
+	keys = append(keys, &active)
+
+	err := validate(keys)
+	if err != nil {
+		return err
+	}
+	kr.keys = keys
+	return nil
+}
+
+// Active returns the key that signs new tokens
+func (kr *Keyring) Active() (*Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, k := range kr.keys {
+		if k.State == StateActive {
+			if !k.ValidAt(time.Now()) {
+				return nil, fmt.Errorf("active key %s is outside its validity window", k.ID)
+			}
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no active key")
+}
+
+// Lookup returns the currently valid key with the given kid
+func (kr *Keyring) Lookup(kid string) (*Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range kr.keys {
+		if k.ID == kid && k.ValidAt(now) {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown kid: %s", kid)
+}
+
+// Keys returns every key that is currently valid for verification, active key first
+func (kr *Keyring) Keys() []*Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := time.Now()
+	var keys []*Key
+	for _, k := range kr.keys {
+		if k.ValidAt(now) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].State == StateActive && keys[j].State != StateActive
+	})
+
+	return keys
+}
+
+// JWKS returns every currently valid public key as a JWK set
+func (kr *Keyring) JWKS() (*jwt.JWKS, error) {
+	set := &jwt.JWKS{Keys: []jwt.JWK{}}
+	for _, k := range kr.Keys() {
+		jwk, err := k.JWK()
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	return set, nil
+}
@@ -0,0 +1,268 @@
+package keyring
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"oauth/pkg/key"
+)
+
+func testKey(t *testing.T, state string) *Key {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	k, err := NewKey(private, "", state)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+	return k
+}
+
+func TestNew(t *testing.T) {
+	active := testKey(t, StateActive)
+	other := testKey(t, StateActive)
+	duplicate := *active
+	duplicate.State = StateRetired
+	unknown := testKey(t, "revoked")
+
+	tests := []struct {
+		name string
+		keys []*Key
+	}{
+		{"empty", nil},
+		{"no active key", []*Key{testKey(t, StatePending)}},
+		{"two active keys", []*Key{active, other}},
+		{"duplicate kid", []*Key{active, &duplicate}},
+		{"unknown state", []*Key{active, unknown}},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.keys)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", tt.name)
+		}
+	}
+
+	_, err := New([]*Key{active, testKey(t, StatePending), testKey(t, StateRetired)})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+}
+
+func TestNewKeyAlgorithm(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %s", err)
+	}
+
+	k, err := NewKey(private, "", StateActive)
+	if err != nil {
+		t.Fatalf("Failed to create key: %s", err)
+	}
+	if k.Alg != "ES384" {
+		t.Fatalf("Expected default algorithm ES384, got %s", k.Alg)
+	}
+
+	_, err = NewKey(private, "RS256", StateActive)
+	if err == nil {
+		t.Fatal("Expected error for algorithm not matching the key, got nil")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	active := testKey(t, StateActive)
+	pending := testKey(t, StatePending)
+	retired := testKey(t, StateRetired)
+	retired.NotAfter = time.Now().Add(time.Hour)
+	expired := testKey(t, StateRetired)
+	expired.NotAfter = time.Now().Add(-time.Hour)
+	future := testKey(t, StatePending)
+	future.NotBefore = time.Now().Add(time.Hour)
+
+	ring, err := New([]*Key{retired, expired, future, pending, active})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+
+	keys := ring.Keys()
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 valid keys, got %d", len(keys))
+	}
+	if keys[0] != active {
+		t.Fatal("Expected active key first")
+	}
+
+	a, err := ring.Active()
+	if err != nil || a != active {
+		t.Fatalf("Unexpected active key: %v", err)
+	}
+
+	_, err = ring.Lookup(expired.ID)
+	if err == nil {
+		t.Fatal("Expected expired key lookup to fail")
+	}
+	_, err = ring.Lookup(retired.ID)
+	if err != nil {
+		t.Fatalf("Failed to look up retired key: %s", err)
+	}
+
+	jwks, err := ring.JWKS()
+	if err != nil {
+		t.Fatalf("Failed to build JWKS: %s", err)
+	}
+	if len(jwks.Keys) != 3 || jwks.Keys[0].Kid != active.ID {
+		t.Fatalf("Unexpected JWKS: %+v", jwks)
+	}
+}
+
+func TestActiveOutsideWindow(t *testing.T) {
+	active := testKey(t, StateActive)
+	active.NotAfter = time.Now().Add(-time.Minute)
+
+	ring, err := New([]*Key{active})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+
+	_, err = ring.Active()
+	if err == nil {
+		t.Fatal("Expected error for expired active key, got nil")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	first := testKey(t, StateActive)
+	kr, err := New([]*Key{first})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %s", err)
+	}
+
+	second := testKey(t, StateActive)
+	err = kr.Replace(second, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to replace key: %s", err)
+	}
+
+	active, err := kr.Active()
+	if err != nil || active.ID != second.ID {
+		t.Fatalf("Expected key %s to be active", second.ID)
+	}
+	old, err := kr.Lookup(first.ID)
+	if err != nil || old.State != StateRetired {
+		t.Fatalf("Expected previous key to keep verifying as retired")
+	}
+	if first.State != StateActive {
+		t.Fatalf("Expected keys handed out before the swap to be left unchanged")
+	}
+
+	// once its window has passed the retired key is dropped on the next swap
+	err = kr.Replace(testKey(t, StateActive), time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Failed to replace key: %s", err)
+	}
+	err = kr.Replace(testKey(t, StateActive), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to replace key: %s", err)
+	}
+	if len(kr.keys) != 3 {
+		t.Fatalf("Expected expired retired keys to be dropped, got %d keys", len(kr.keys))
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	active := testKey(t, StateActive)
+	retired := testKey(t, StateRetired)
+
+	for name, k := range map[string]*Key{"active.pem": active, "retired.pem": retired} {
+		b, err := key.PrivateBytes(k.Signer, nil)
+		if err != nil {
+			t.Fatalf("Failed to encode key: %s", err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), b, 0600)
+		if err != nil {
+			t.Fatalf("Failed to write key: %s", err)
+		}
+	}
+
+	notAfter := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	m, _ := json.Marshal(manifest{Keys: []manifestKey{
+		{ID: "2024-01", State: StateActive, Path: "active.pem"},
+		{State: StateRetired, NotAfter: notAfter, Path: "retired.pem"},
+	}})
+	err := os.WriteFile(filepath.Join(dir, "keyring.json"), m, 0600)
+	if err != nil {
+		t.Fatalf("Failed to write manifest: %s", err)
+	}
+
+	keys, err := LoadFile(filepath.Join(dir, "keyring.json"), nil)
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %s", err)
+	}
+
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(keys))
+	}
+	if keys[0].ID != "2024-01" || !keys[0].Signer.(*rsa.PrivateKey).Equal(active.Signer) {
+		t.Fatalf("Unexpected active key: %s", keys[0].ID)
+	}
+	if keys[1].ID != retired.ID || !keys[1].NotAfter.Equal(notAfter) {
+		t.Fatalf("Expected retired key to default to its thumbprint and keep not_after, got %s %v", keys[1].ID, keys[1].NotAfter)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	fs := NewFileStore(filepath.Join(dir, "keyring.json"), nil)
+
+	_, err := fs.Load(context.Background())
+	if err == nil {
+		t.Fatalf("Expected error loading missing manifest, got nil")
+	}
+
+	active := testKey(t, StateActive)
+	retired := testKey(t, StateRetired)
+	retired.NotAfter = time.Now().Add(time.Hour).Truncate(time.Second)
+	err = fs.Save(context.Background(), []*Key{active, retired})
+	if err != nil {
+		t.Fatalf("Failed to save keys: %s", err)
+	}
+
+	paths, err := fs.Paths()
+	if err != nil {
+		t.Fatalf("Failed to list paths: %s", err)
+	}
+	if len(paths) != 3 || paths[0] != filepath.Join(dir, "keyring.json") || paths[1] != filepath.Join(dir, active.ID+".pem") {
+		t.Fatalf("Expected the manifest and both key files, got %v", paths)
+	}
+
+	keys, err := fs.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load keys: %s", err)
+	}
+	if len(keys) != 2 || keys[0].ID != active.ID || keys[1].ID != retired.ID {
+		t.Fatalf("Unexpected keys after load")
+	}
+	if !keys[1].NotAfter.Equal(retired.NotAfter) {
+		t.Fatalf("Expected not_after %s, got %s", retired.NotAfter, keys[1].NotAfter)
+	}
+
+	err = fs.Save(context.Background(), []*Key{active})
+	if err != nil {
+		t.Fatalf("Failed to save keys: %s", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, retired.ID+".pem"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("Expected dropped key file to be removed, got %v", err)
+	}
+}
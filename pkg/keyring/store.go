@@ -0,0 +1,21 @@
+package keyring
+
+import "context"
+
+// Store persists the keys of a keyring
+type Store interface {
+	// Load returns every stored key
+	Load(ctx context.Context) ([]*Key, error)
+	// Save replaces the stored keys with keys
+	Save(ctx context.Context, keys []*Key) error
+}
+
+// Reload replaces the keys of kr with the keys in store
+func (kr *Keyring) Reload(ctx context.Context, store Store) error {
+	keys, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	return kr.Set(keys)
+}
@@ -0,0 +1,52 @@
+package reload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Certificate is a TLS certificate that can be replaced while serving
+type Certificate struct {
+	certPath string
+	keyPath  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// LoadCertificate reads the PEM encoded certificate chain and key
+func LoadCertificate(certPath string, keyPath string) (*Certificate, error) {
+	c := &Certificate{certPath: certPath, keyPath: keyPath}
+	err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload reads the files again and swaps in the new certificate once it is
+// known to be usable. On error the current certificate is kept.
+func (c *Certificate) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %s", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %s", err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired at %s", leaf.NotAfter)
+	}
+	cert.Leaf = leaf
+
+	c.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns the current certificate, for use in tls.Config
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
@@ -0,0 +1,81 @@
+package reload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for cn that expires at notAfter
+func writeCertificate(t *testing.T, dir string, cn string, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %s", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "tls.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, "tls.key"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, dir, "first", time.Now().Add(time.Hour))
+
+	c, err := LoadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %s", err)
+	}
+
+	writeCertificate(t, dir, "second", time.Now().Add(time.Hour))
+	err = c.Reload()
+	if err != nil {
+		t.Fatalf("Failed to reload certificate: %s", err)
+	}
+	cert, _ := c.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("Expected reloaded certificate, got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	// an expired certificate is rejected and the current one kept
+	writeCertificate(t, dir, "expired", time.Now().Add(-time.Hour))
+	err = c.Reload()
+	if err == nil {
+		t.Fatal("Expected error for an expired certificate, got nil")
+	}
+
+	// so is a key that does not match the certificate
+	writeCertificate(t, dir, "third", time.Now().Add(time.Hour))
+	os.WriteFile(certPath, []byte("not a certificate"), 0600)
+	err = c.Reload()
+	if err == nil {
+		t.Fatal("Expected error for an invalid certificate, got nil")
+	}
+
+	cert, _ = c.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("Expected previous certificate to be kept, got %s", cert.Leaf.Subject.CommonName)
+	}
+}
@@ -0,0 +1,196 @@
+package reload
+
+import (
+	"crypto/sha256"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settle is how long the watcher waits after the last file event before
+// reloading, so files written in several steps are read once complete
+const settle = 250 * time.Millisecond
+
+// Reload metrics, exported at /debug/vars and keyed by the name of what was reloaded
+var (
+	reloads        = expvar.NewMap("reloads")
+	reloadFailures = expvar.NewMap("reload_failures")
+	lastReload     = expvar.NewMap("last_reload_unix")
+)
+
+type entry struct {
+	name   string
+	paths  func() ([]string, error)
+	reload func() error
+	sum    [sha256.Size]byte
+}
+
+// Watcher calls a reload function whenever the contents of its files change.
+// Directories are watched rather than files, so files replaced by rename or
+// by a symlink swap, as Kubernetes does for mounted secrets, are picked up.
+type Watcher struct {
+	mu      sync.Mutex
+	fw      *fsnotify.Watcher
+	dirs    map[string]bool
+	entries []*entry
+	done    chan bool
+	once    sync.Once
+}
+
+// NewWatcher returns a Watcher with nothing to watch yet
+func NewWatcher() (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{fw: fw, dirs: map[string]bool{}, done: make(chan bool)}, nil
+}
+
+// Add calls reload after paths change. name labels logs and metrics. Files
+// are considered unchanged until reload succeeds, so a failed reload is retried
+// on the next change.
+func (w *Watcher) Add(name string, paths []string, reload func() error) error {
+	return w.AddFunc(name, func() ([]string, error) { return paths, nil }, reload)
+}
+
+// AddFunc is Add for a set of files that changes over time, such as a manifest
+// and the files it lists. paths is called again before every check, so files
+// it starts returning are watched from then on.
+func (w *Watcher) AddFunc(name string, paths func() ([]string, error), reload func() error) error {
+	current, err := paths()
+	if err != nil {
+		return err
+	}
+	sum, err := checksum(current)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err = w.watchDirs(current)
+	if err != nil {
+		return err
+	}
+	w.entries = append(w.entries, &entry{name: name, paths: paths, reload: reload, sum: sum})
+
+	return nil
+}
+
+// watchDirs watches the directories holding paths. w.mu must be held.
+func (w *Watcher) watchDirs(paths []string) error {
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if w.dirs[dir] {
+			continue
+		}
+		err := w.fw.Add(dir)
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %s", dir, err)
+		}
+		w.dirs[dir] = true
+	}
+
+	return nil
+}
+
+// Start watches for changes in the background until Close is called
+func (w *Watcher) Start() {
+	go func() {
+		timer := time.NewTimer(settle)
+		timer.Stop()
+		for {
+			select {
+			case <-w.done:
+				timer.Stop()
+				return
+			case _, ok := <-w.fw.Events:
+				if !ok {
+					return
+				}
+				timer.Reset(settle)
+			case err, ok := <-w.fw.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("reload: watch error: %s\n", err)
+			case <-timer.C:
+				w.check()
+			}
+		}
+	}()
+}
+
+// Close stops watching. It does not block, whether or not Start was called,
+// and may be called more than once.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+		w.fw.Close()
+	})
+}
+
+// check reloads every entry whose files changed
+func (w *Watcher) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, e := range w.entries {
+		paths, err := e.paths()
+		if err != nil {
+			// a manifest may be missing for a moment while it is replaced
+			continue
+		}
+		err = w.watchDirs(paths)
+		if err != nil {
+			fmt.Printf("reload: %s: %s\n", e.name, err)
+		}
+		sum, err := checksum(paths)
+		if err != nil {
+			// a file may be missing for a moment while it is replaced
+			continue
+		}
+		if sum == e.sum {
+			continue
+		}
+
+		err = e.reload()
+		if err != nil {
+			reloadFailures.Add(e.name, 1)
+			fmt.Printf("reload: %s: keeping current version: %s\n", e.name, err)
+			continue
+		}
+		e.sum = sum
+		reloads.Add(e.name, 1)
+		lastReload.Set(e.name, intVar(time.Now().Unix()))
+		fmt.Printf("reload: %s reloaded\n", e.name)
+	}
+}
+
+func checksum(paths []string) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		h.Write(b)
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+func intVar(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
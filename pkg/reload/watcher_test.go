@@ -0,0 +1,168 @@
+package reload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key.pem")
+	err := os.WriteFile(path, []byte("v1"), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %s", err)
+	}
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %s", err)
+	}
+	reloaded := make(chan string, 10)
+	err = w.Add("test_watcher", []string{path}, func() error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if string(b) == "invalid" {
+			return fmt.Errorf("invalid contents")
+		}
+		reloaded <- string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch file: %s", err)
+	}
+	w.Start()
+	defer w.Close()
+
+	// replace by rename, as cert-manager and editors do
+	tmp := filepath.Join(dir, "key.pem.tmp")
+	os.WriteFile(tmp, []byte("v2"), 0600)
+	err = os.Rename(tmp, path)
+	if err != nil {
+		t.Fatalf("Failed to replace file: %s", err)
+	}
+	select {
+	case v := <-reloaded:
+		if v != "v2" {
+			t.Fatalf("Expected v2, got %s", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for reload")
+	}
+	if reloads.Get("test_watcher").String() != "1" {
+		t.Fatalf("Expected 1 reload, got %s", reloads.Get("test_watcher"))
+	}
+
+	os.WriteFile(path, []byte("invalid"), 0600)
+	waitFor(t, "failed reload", func() bool {
+		v := reloadFailures.Get("test_watcher")
+		return v != nil && v.String() == "1"
+	})
+
+	// the failed version is retried, a fixed file reloads again
+	os.WriteFile(path, []byte("v3"), 0600)
+	select {
+	case v := <-reloaded:
+		if v != "v3" {
+			t.Fatalf("Expected v3, got %s", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for reload")
+	}
+}
+
+func TestWatcherAddFunc(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest")
+	first := filepath.Join(dir, "first.pem")
+	// the second key lives in a directory that is not watched yet
+	second := filepath.Join(dir, "keys", "second.pem")
+	os.Mkdir(filepath.Join(dir, "keys"), 0700)
+	os.WriteFile(first, []byte("first"), 0600)
+	os.WriteFile(second, []byte("second"), 0600)
+	err := os.WriteFile(manifest, []byte(first), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write manifest: %s", err)
+	}
+
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %s", err)
+	}
+	reloaded := make(chan bool, 10)
+	err = w.AddFunc("test_watcher_func", func() ([]string, error) {
+		b, err := os.ReadFile(manifest)
+		if err != nil {
+			return nil, err
+		}
+		return []string{manifest, string(b)}, nil
+	}, func() error {
+		reloaded <- true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch files: %s", err)
+	}
+	w.Start()
+	defer w.Close()
+
+	wait := func(what string) {
+		select {
+		case <-reloaded:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for reload after %s", what)
+		}
+	}
+
+	os.WriteFile(first, []byte("first v2"), 0600)
+	wait("changing a listed key")
+
+	os.WriteFile(manifest, []byte(second), 0600)
+	wait("changing the manifest")
+
+	os.WriteFile(second, []byte("second v2"), 0600)
+	wait("changing a newly listed key")
+}
+
+func TestWatcherClose(t *testing.T) {
+	closed := make(chan bool)
+	go func() {
+		w, err := NewWatcher()
+		if err != nil {
+			t.Errorf("Failed to create watcher: %s", err)
+		}
+		// never started
+		w.Close()
+		w.Close()
+
+		w, err = NewWatcher()
+		if err != nil {
+			t.Errorf("Failed to create watcher: %s", err)
+		}
+		w.Start()
+		w.Close()
+		w.Close()
+		closed <- true
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Close")
+	}
+}
@@ -0,0 +1,143 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"oauth/pkg/jwt"
+)
+
+// kmsSigner signs with a key held by a remote KMS. The KMS serves the public
+// key as a JWK from GET {url}/keys/{id} and signs at POST {url}/keys/{id}/sign,
+// which takes {"alg": "ES256", "digest": "<base64url>"} and answers
+// {"signature": "<base64url>"}. For EdDSA the digest is the whole message and
+// ECDSA signatures are ASN.1 encoded, as crypto.Signer returns them.
+type kmsSigner struct {
+	c      *http.Client
+	url    string
+	token  string
+	public crypto.PublicKey
+}
+
+type kmsSignRequest struct {
+	Alg    string `json:"alg"`
+	Digest string `json:"digest"`
+}
+
+type kmsSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+// NewKMSSigner returns a Signer for the key with id at the KMS served from
+// baseURL. token is sent as a bearer token when it is not empty.
+func NewKMSSigner(c *http.Client, baseURL string, id string, token string) (*kmsSigner, error) {
+	ks := &kmsSigner{
+		c:     c,
+		url:   fmt.Sprintf("%s/keys/%s", baseURL, url.PathEscape(id)),
+		token: token,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ks.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwk jwt.JWK
+	err = ks.do(req, &jwk)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch public key: %s", err)
+	}
+	ks.public, err = jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+func (ks *kmsSigner) Public() crypto.PublicKey {
+	return ks.public
+}
+
+func (ks *kmsSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg, err := kmsAlgorithm(ks.public, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(kmsSignRequest{Alg: alg, Digest: base64.RawURLEncoding.EncodeToString(digest)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, ks.url+"/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp kmsSignResponse
+	err = ks.do(req, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("kms sign failed: %s", err)
+	}
+
+	return base64.RawURLEncoding.DecodeString(resp.Signature)
+}
+
+func (ks *kmsSigner) do(req *http.Request, v any) error {
+	if ks.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ks.token)
+	}
+
+	resp, err := ks.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// kmsAlgorithm names the JWS algorithm a signature with key and opts produces
+func kmsAlgorithm(key crypto.PublicKey, opts crypto.SignerOpts) (string, error) {
+	var size string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		size = "256"
+	case crypto.SHA384:
+		size = "384"
+	case crypto.SHA512:
+		size = "512"
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok && size != "" {
+			return "PS" + size, nil
+		}
+		if size != "" {
+			return "RS" + size, nil
+		}
+	case *ecdsa.PublicKey:
+		if size != "" {
+			return "ES" + size, nil
+		}
+	case ed25519.PublicKey:
+		if opts.HashFunc() == 0 {
+			return "EdDSA", nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported signature for %T", key)
+}
@@ -0,0 +1,122 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"oauth/pkg/jwt"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+)
+
+// stubKMS serves one key the way a remote KMS would
+func stubKMS(t *testing.T, id string, private crypto.Signer) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/keys/"+id:
+			jwk, err := jwt.NewJWK(private.Public(), id, "")
+			if err != nil {
+				t.Errorf("Failed to create JWK: %s", err)
+			}
+			json.NewEncoder(w).Encode(jwk)
+		case r.Method == http.MethodPost && r.URL.Path == "/keys/"+id+"/sign":
+			var req kmsSignRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			digest, _ := base64.RawURLEncoding.DecodeString(req.Digest)
+
+			var opts crypto.SignerOpts
+			switch req.Alg {
+			case "ES256", "RS256":
+				opts = crypto.SHA256
+			case "PS256":
+				opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+			case "EdDSA":
+				opts = crypto.Hash(0)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			sig, err := private.Sign(rand.Reader, digest, opts)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(kmsSignResponse{Signature: base64.RawURLEncoding.EncodeToString(sig)})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestKMSSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %s", err)
+	}
+
+	tests := []struct {
+		alg string
+		key crypto.Signer
+	}{
+		{"RS256", rsaKey},
+		{"PS256", rsaKey},
+		{"ES256", ecKey},
+		{"EdDSA", edKey},
+	}
+
+	for _, tt := range tests {
+		srv := stubKMS(t, "token-key", tt.key)
+		defer srv.Close()
+
+		s, err := NewKMSSigner(srv.Client(), srv.URL, "token-key", "secret")
+		if err != nil {
+			t.Fatalf("%s: failed to create signer: %s", tt.alg, err)
+		}
+
+		signed, err := jwt.Sign(gojwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()}, tt.alg, "token-key", s)
+		if err != nil {
+			t.Fatalf("%s: failed to sign token: %s", tt.alg, err)
+		}
+
+		_, err = jwt.NewValidator(tt.key.Public()).Validate(signed)
+		if err != nil {
+			t.Fatalf("%s: failed to validate token: %s", tt.alg, err)
+		}
+	}
+}
+
+func TestKMSSignerUnauthorized(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %s", err)
+	}
+	srv := stubKMS(t, "token-key", ecKey)
+	defer srv.Close()
+
+	_, err = NewKMSSigner(srv.Client(), srv.URL, "token-key", "wrong")
+	if err == nil {
+		t.Fatal("Expected error for rejected credentials, got nil")
+	}
+}
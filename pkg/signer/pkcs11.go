@@ -0,0 +1,295 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"unsafe"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Config selects a key pair on a PKCS #11 token
+type PKCS11Config struct {
+	// Module is the path of the PKCS #11 library, e.g. libsofthsm2.so
+	Module     string
+	TokenLabel string
+	PIN        string
+	// KeyLabel is the CKA_LABEL shared by the private and public key
+	KeyLabel string
+}
+
+// pkcs11Signer signs with an RSA or EC private key that never leaves the token
+type pkcs11Signer struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+// digestInfoPrefixes are the DER DigestInfo headers CKM_RSA_PKCS expects in
+// front of the digest (RFC 8017 section 9.2)
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+var pssParams = map[crypto.Hash][2]uint{
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+var curveOIDs = map[string]elliptic.Curve{
+	"1.2.840.10045.3.1.7": elliptic.P256(),
+	"1.3.132.0.34":        elliptic.P384(),
+	"1.3.132.0.35":        elliptic.P521(),
+}
+
+// NewPKCS11Signer logs into the token labelled cfg.TokenLabel and returns a
+// Signer for the key pair labelled cfg.KeyLabel
+func NewPKCS11Signer(cfg PKCS11Config) (Signer, error) {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("unable to load pkcs11 module: %s", cfg.Module)
+	}
+	err := ctx.Initialize()
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("unable to initialize pkcs11 module: %s", err)
+	}
+
+	ps := &pkcs11Signer{ctx: ctx}
+	err = ps.open(cfg)
+	if err != nil {
+		ps.Close()
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func (ps *pkcs11Signer) open(cfg PKCS11Config) error {
+	slots, err := ps.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("unable to list pkcs11 slots: %s", err)
+	}
+
+	found := false
+	var slot uint
+	for _, s := range slots {
+		info, err := ps.ctx.GetTokenInfo(s)
+		if err == nil && info.Label == cfg.TokenLabel {
+			slot, found = s, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("pkcs11 token not found: %s", cfg.TokenLabel)
+	}
+
+	ps.session, err = ps.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("unable to open pkcs11 session: %s", err)
+	}
+	err = ps.ctx.Login(ps.session, pkcs11.CKU_USER, cfg.PIN)
+	if err != nil {
+		return fmt.Errorf("unable to log into pkcs11 token: %s", err)
+	}
+
+	ps.key, err = ps.find(pkcs11.CKO_PRIVATE_KEY, cfg.KeyLabel)
+	if err != nil {
+		return err
+	}
+	public, err := ps.find(pkcs11.CKO_PUBLIC_KEY, cfg.KeyLabel)
+	if err != nil {
+		return err
+	}
+	ps.public, err = ps.publicKey(public)
+	return err
+}
+
+func (ps *pkcs11Signer) find(class uint, label string) (pkcs11.ObjectHandle, error) {
+	err := ps.ctx.FindObjectsInit(ps.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer ps.ctx.FindObjectsFinal(ps.session)
+
+	objects, _, err := ps.ctx.FindObjects(ps.session, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("pkcs11 key not found: %s", label)
+	}
+
+	return objects[0], nil
+}
+
+func (ps *pkcs11Signer) publicKey(o pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := ps.ctx.GetAttributeValue(ps.session, o, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyType, err := ulong(attrs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := ps.ctx.GetAttributeValue(ps.session, o, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	case pkcs11.CKK_EC:
+		attrs, err := ps.ctx.GetAttributeValue(ps.session, o, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ecPublicKey(attrs[0].Value, attrs[1].Value)
+	}
+
+	return nil, fmt.Errorf("unsupported pkcs11 key type: %d", keyType)
+}
+
+// nativeEndian is the byte order of the host, which CK_ULONG attribute values use
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// ulong decodes a CK_ULONG attribute value. Its size follows the C unsigned
+// long of the platform, 4 or 8 bytes.
+func ulong(b []byte) (uint64, error) {
+	switch len(b) {
+	case 4:
+		return uint64(nativeEndian.Uint32(b)), nil
+	case 8:
+		return nativeEndian.Uint64(b), nil
+	}
+	return 0, fmt.Errorf("invalid CK_ULONG attribute of %d bytes", len(b))
+}
+
+// ecPublicKey decodes the curve OID in params and the DER wrapped uncompressed point
+func ecPublicKey(params []byte, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	_, err := asn1.Unmarshal(params, &oid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC parameters: %s", err)
+	}
+	curve, ok := curveOIDs[oid.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve: %s", oid)
+	}
+
+	var raw []byte
+	_, err = asn1.Unmarshal(point, &raw)
+	if err != nil {
+		// some modules return the point without the OCTET STRING wrapper
+		raw = point
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(raw) != 1+2*size || raw[0] != 4 {
+		return nil, fmt.Errorf("invalid EC point")
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(raw[1 : 1+size]),
+		Y:     new(big.Int).SetBytes(raw[1+size:]),
+	}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("invalid EC point")
+	}
+	return key, nil
+}
+
+func (ps *pkcs11Signer) Public() crypto.PublicKey {
+	return ps.public
+}
+
+func (ps *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism *pkcs11.Mechanism
+	input := digest
+	switch ps.public.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			params, ok := pssParams[opts.HashFunc()]
+			if !ok || pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+				return nil, fmt.Errorf("unsupported PSS options")
+			}
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pkcs11.NewPSSParams(params[0], params[1], uint(opts.HashFunc().Size())))
+			break
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash: %s", opts.HashFunc())
+		}
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		input = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	err := ps.ctx.SignInit(ps.session, []*pkcs11.Mechanism{mechanism}, ps.key)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 sign failed: %s", err)
+	}
+	sig, err := ps.ctx.Sign(ps.session, input)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 sign failed: %s", err)
+	}
+
+	if _, ok := ps.public.(*ecdsa.PublicKey); ok {
+		// PKCS #11 returns r || s while crypto.Signer callers expect ASN.1
+		half := len(sig) / 2
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{new(big.Int).SetBytes(sig[:half]), new(big.Int).SetBytes(sig[half:])})
+	}
+
+	return sig, nil
+}
+
+// Close logs out and unloads the module
+func (ps *pkcs11Signer) Close() {
+	ps.ctx.Logout(ps.session)
+	ps.ctx.CloseSession(ps.session)
+	ps.ctx.Finalize()
+	ps.ctx.Destroy()
+}
@@ -0,0 +1,18 @@
+//go:build !pkcs11
+
+package signer
+
+import "fmt"
+
+// PKCS11Config selects a key pair on a PKCS #11 token
+type PKCS11Config struct {
+	Module     string
+	TokenLabel string
+	PIN        string
+	KeyLabel   string
+}
+
+// NewPKCS11Signer needs cgo, so it is only available when built with the pkcs11 tag
+func NewPKCS11Signer(cfg PKCS11Config) (Signer, error) {
+	return nil, fmt.Errorf("pkcs11 support is not compiled in, build with -tags pkcs11")
+}
@@ -0,0 +1,161 @@
+//go:build pkcs11
+
+package signer
+
+import (
+	"encoding/asn1"
+	"oauth/pkg/jwt"
+	"os"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+	"github.com/miekg/pkcs11"
+)
+
+// TestPKCS11Signer runs against SoftHSM, e.g.
+//
+//	softhsm2-util --init-token --free --label oauth --pin 1234 --so-pin 1234
+//	PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_TOKEN_LABEL=oauth PKCS11_PIN=1234 go test -tags pkcs11 ./pkg/signer
+func TestPKCS11Signer(t *testing.T) {
+	cfg := PKCS11Config{
+		Module:     os.Getenv("PKCS11_MODULE"),
+		TokenLabel: os.Getenv("PKCS11_TOKEN_LABEL"),
+		PIN:        os.Getenv("PKCS11_PIN"),
+		KeyLabel:   "oauth-test-" + time.Now().Format("20060102150405"),
+	}
+	if cfg.Module == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+	generateKeyPairs(t, cfg)
+
+	tests := []struct {
+		alg   string
+		label string
+	}{
+		{"RS256", cfg.KeyLabel + "-rsa"},
+		{"PS256", cfg.KeyLabel + "-rsa"},
+		{"ES256", cfg.KeyLabel + "-ec"},
+	}
+
+	for _, tt := range tests {
+		c := cfg
+		c.KeyLabel = tt.label
+		s, err := NewPKCS11Signer(c)
+		if err != nil {
+			t.Fatalf("%s: failed to create signer: %s", tt.alg, err)
+		}
+
+		signed, err := jwt.Sign(gojwt.StandardClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()}, tt.alg, "hsm-key", s)
+		if err != nil {
+			t.Fatalf("%s: failed to sign token: %s", tt.alg, err)
+		}
+		_, err = jwt.NewValidator(s.Public()).Validate(signed)
+		if err != nil {
+			t.Fatalf("%s: failed to validate token: %s", tt.alg, err)
+		}
+		s.(*pkcs11Signer).Close()
+	}
+}
+
+// TestULong decodes CK_ULONG values the way pkcs11 encodes them, in host byte order
+func TestULong(t *testing.T) {
+	for _, v := range []uint{pkcs11.CKK_RSA, pkcs11.CKK_EC, pkcs11.CKO_PRIVATE_KEY} {
+		got, err := ulong(pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, v).Value)
+		if err != nil || got != uint64(v) {
+			t.Fatalf("Expected %d, got %d (%v)", v, got, err)
+		}
+	}
+
+	_, err := ulong([]byte{1, 2, 3})
+	if err == nil {
+		t.Fatal("Expected error for a 3 byte value, got nil")
+	}
+}
+
+// openToken logs into the configured token with a read/write session
+func openToken(t *testing.T, cfg PKCS11Config) *pkcs11Signer {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		t.Fatalf("Failed to load module %s", cfg.Module)
+	}
+	ctx.Initialize()
+	ps := &pkcs11Signer{ctx: ctx}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatalf("Failed to list slots: %s", err)
+	}
+	for _, s := range slots {
+		info, err := ctx.GetTokenInfo(s)
+		if err != nil || info.Label != cfg.TokenLabel {
+			continue
+		}
+		ps.session, err = ctx.OpenSession(s, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			t.Fatalf("Failed to open session: %s", err)
+		}
+		err = ctx.Login(ps.session, pkcs11.CKU_USER, cfg.PIN)
+		if err != nil {
+			t.Fatalf("Failed to log in: %s", err)
+		}
+		return ps
+	}
+
+	t.Fatalf("Token %s not found", cfg.TokenLabel)
+	return nil
+}
+
+// generateKeyPairs creates RSA and P-256 key pairs on the token and removes
+// them when the test ends
+func generateKeyPairs(t *testing.T, cfg PKCS11Config) {
+	ps := openToken(t, cfg)
+	defer ps.Close()
+
+	p256, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	pairs := []struct {
+		mechanism uint
+		label     string
+		public    []*pkcs11.Attribute
+	}{
+		{pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, cfg.KeyLabel + "-rsa", []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		}},
+		{pkcs11.CKM_EC_KEY_PAIR_GEN, cfg.KeyLabel + "-ec", []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, p256),
+		}},
+	}
+
+	for _, p := range pairs {
+		public := append(p.public,
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.label),
+		)
+		private := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.label),
+		}
+		_, _, err := ps.ctx.GenerateKeyPair(ps.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(p.mechanism, nil)}, public, private)
+		if err != nil {
+			t.Fatalf("Failed to generate key pair: %s", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		ps := openToken(t, cfg)
+		defer ps.Close()
+		for _, p := range pairs {
+			for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+				o, err := ps.find(class, p.label)
+				if err == nil {
+					ps.ctx.DestroyObject(ps.session, o)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package signer
+
+import (
+	"crypto"
+	"io"
+	"oauth/pkg/key"
+)
+
+// Signer signs with a private key that may never leave a file, an HSM or a
+// remote KMS. It follows crypto.Signer, so local keys satisfy it as they are.
+type Signer interface {
+	// Public returns the public key matching the private key
+	Public() crypto.PublicKey
+	// Sign signs digest, or the whole message for Ed25519, as crypto.Signer does
+	Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// NewFileSigner returns a Signer for the PEM encoded private key at path,
+// decrypting it with passphrase when it is encrypted
+func NewFileSigner(path string, passphrase []byte) (Signer, error) {
+	return key.GetPrivateKey(path, passphrase)
+}